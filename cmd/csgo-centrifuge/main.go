@@ -3,14 +3,22 @@ package main
 import (
 	"archive/zip"
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"image/png"
 	"io"
+	"io/ioutil"
 	"log"
+	"net/http"
 	"os"
+	"path"
 	"path/filepath"
 	"sort"
 	"strings"
+	"time"
 
 	"github.com/galaco/bsp"
 	"github.com/galaco/vpk2"
@@ -19,6 +27,9 @@ import (
 
 	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
 	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crcdb"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/mirror"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/server"
 	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
 )
 
@@ -104,7 +115,91 @@ func extractFile(zipR *zip.Reader, file, outPath string) error {
 	return nil
 }
 
-func extractRadarOverview(bspPath, outDirPath string) error {
+func extractRadarImagePNG(zipR *zip.Reader, file, outPath string) error {
+	f, err := zipR.Open(file)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %q in zip", file)
+	}
+	defer f.Close()
+
+	img, err := bsputil.DecodeRadarImage(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to decode radar image %q", file)
+	}
+
+	fOut, err := os.Create(outPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create out file %q", outPath)
+	}
+	defer fOut.Close()
+
+	err = png.Encode(fOut, img)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode radar image as PNG to %q", outPath)
+	}
+
+	return nil
+}
+
+// radarManifest lists the files written by extractRadarOverview.
+type radarManifest struct {
+	MapName   string               `json:"map_name"`
+	InfoFile  string               `json:"info_file"`
+	Info      *bsputil.RadarInfo   `json:"info"`
+	InfoError string               `json:"info_error,omitempty"`
+	Assets    []radarManifestAsset `json:"assets"`
+	// Errors describe radar images that couldn't be converted to PNG, .dds files are always extracted as-is.
+	Errors []string `json:"errors,omitempty"`
+}
+
+type radarManifestAsset struct {
+	bsputil.RadarAsset
+	File string `json:"file"`
+	// MipMapFiles are the mipmaps of the image (without the main surface), largest first.
+	MipMapFiles []string `json:"mipmap_files,omitempty"`
+}
+
+// extractRadarMipMapsPNG writes the mipmaps of a radar image to <outDir>/<base>_mip<level>.png and returns the file names.
+func extractRadarMipMapsPNG(zipR *zip.Reader, file, outDir, base string) ([]string, error) {
+	f, err := zipR.Open(file)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open file %q in zip", file)
+	}
+	defer f.Close()
+
+	imgs, err := bsputil.DecodeRadarMipMaps(f)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to decode radar image %q", file)
+	}
+
+	var names []string
+
+	for level, img := range imgs[1:] {
+		name := fmt.Sprintf("%s_mip%d.png", base, level+1)
+
+		var buf bytes.Buffer
+
+		err = png.Encode(&buf, img)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to encode mipmap %d as PNG", level+1)
+		}
+
+		err = ioutil.WriteFile(filepath.Join(outDir, name), buf.Bytes(), 0666)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to write mipmap %q", name)
+		}
+
+		names = append(names, name)
+	}
+
+	return names, nil
+}
+
+func extractRadarOverview(bspPath, outDirPath, format string, mipMaps bool) error {
+	if format != "dds" && format != "png" {
+		return errors.Errorf("unsupported radar image format %q, must be one of: dds, png", format)
+	}
+
 	bspF, err := pathToBsp(bspPath)
 	if err != nil {
 		return errors.Wrap(err, "failed to read BSP data")
@@ -115,9 +210,9 @@ func extractRadarOverview(bspPath, outDirPath string) error {
 		return errors.Wrap(err, "failed to read pakfile data")
 	}
 
-	mapName, err := bsputil.GetMapName(pakfile)
+	assets, err := bsputil.GetRadarAssets(pakfile)
 	if err != nil {
-		return errors.Wrap(err, "failed to get map name from pakfile")
+		return errors.Wrap(err, "failed to get radar images from pakfile")
 	}
 
 	err = os.MkdirAll(outDirPath, 0777)
@@ -125,27 +220,283 @@ func extractRadarOverview(bspPath, outDirPath string) error {
 		return errors.Wrapf(err, "failed to create out dir %q", outDirPath)
 	}
 
-	ddsPath := fmt.Sprintf("resource/overviews/%s_radar.dds", mapName)
-	err = extractFile(pakfile, ddsPath, filepath.Join(outDirPath, fmt.Sprintf("%s_radar.dds", mapName)))
-	if err != nil {
-		return errors.Wrapf(err, "failed to extract file %q from pakfile", ddsPath)
+	manifest := radarManifest{
+		MapName:   assets.MapName,
+		InfoFile:  fmt.Sprintf("%s.txt", assets.MapName),
+		Info:      assets.Info,
+		InfoError: assets.InfoError,
+		Assets:    make([]radarManifestAsset, 0, len(assets.Assets)),
+	}
+
+	if assets.InfoError != "" {
+		log.Printf("warning: %s", assets.InfoError)
+	}
+
+	for _, asset := range assets.Assets {
+		if format == "png" && asset.HeaderError != "" {
+			log.Printf("skipping radar image: %s", asset.HeaderError)
+			manifest.Errors = append(manifest.Errors, asset.HeaderError)
+
+			continue
+		}
+
+		base := strings.TrimSuffix(path.Base(asset.Path), ".dds")
+		res := radarManifestAsset{
+			RadarAsset: asset,
+			File:       base + "." + format,
+		}
+
+		outPath := filepath.Join(outDirPath, res.File)
+
+		if format == "png" {
+			err = extractRadarImagePNG(pakfile, asset.Path, outPath)
+		} else {
+			err = extractFile(pakfile, asset.Path, outPath)
+		}
+		if err != nil {
+			return errors.Wrapf(err, "failed to extract file %q from pakfile", asset.Path)
+		}
+
+		if mipMaps && format == "png" && asset.MipMaps > 1 {
+			res.MipMapFiles, err = extractRadarMipMapsPNG(pakfile, asset.Path, outDirPath, base)
+			if err != nil {
+				return errors.Wrapf(err, "failed to extract mipmaps of %q from pakfile", asset.Path)
+			}
+		}
+
+		manifest.Assets = append(manifest.Assets, res)
 	}
 
-	txtPath := fmt.Sprintf("resource/overviews/%s.txt", mapName)
-	err = extractFile(pakfile, txtPath, filepath.Join(outDirPath, fmt.Sprintf("%s.txt", mapName)))
+	if len(manifest.Assets) == 0 {
+		return errors.Wrapf(bsputil.ErrRadarImageNotFound, "none of the radar images of %s can be converted to PNG", assets.MapName)
+	}
+
+	txtPath := fmt.Sprintf("resource/overviews/%s.txt", assets.MapName)
+	err = extractFile(pakfile, txtPath, filepath.Join(outDirPath, manifest.InfoFile))
 	if err != nil {
 		return errors.Wrapf(err, "failed to extract file %q from pakfile", txtPath)
 	}
 
+	manifestPath := filepath.Join(outDirPath, fmt.Sprintf("%s_manifest.json", assets.MapName))
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode radar manifest as JSON")
+	}
+
+	err = ioutil.WriteFile(manifestPath, b, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write radar manifest %q", manifestPath)
+	}
+
+	log.Printf("extracted %d radar images of %s, see %s", len(manifest.Assets), assets.MapName, manifestPath)
+
 	return nil
 }
 
-func download(workshopFileID int, outPath string) error {
-	var (
-		w   io.Writer
-		err error
-	)
+func bspInfo(bspPath, outPath string) error {
+	bspF, err := pathToBsp(bspPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read BSP data")
+	}
+
+	info, err := bsputil.GetInfo(bspF)
+	if err != nil {
+		return errors.Wrap(err, "failed to get BSP info")
+	}
+
+	var w io.Writer
+	if outPath == "-" {
+		w = os.Stdout
+	} else {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create out file: %q", outPath)
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(info)
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode BSP info as JSON to output file %q", outPath)
+	}
+
+	return nil
+}
+
+// bspEntities prints the typed gameplay entities (with brush entity geometry) and all raw entities of the entity lump as JSON.
+func bspEntities(bspPath, outPath string) error {
+	bspF, err := pathToBsp(bspPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read BSP data")
+	}
+
+	entities, err := bsputil.GetEntities(bspF)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse entity lump")
+	}
+
+	gameplay, err := entities.Gameplay()
+	if err != nil {
+		return errors.Wrap(err, "failed to parse gameplay entities")
+	}
+
+	models, err := bsputil.LoadBrushModels(bspF)
+	if err != nil {
+		return errors.Wrap(err, "failed to load brush models")
+	}
+
+	gameplay.ResolveGeometry(models)
+
+	var w io.Writer
+	if outPath == "-" {
+		w = os.Stdout
+	} else {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create out file: %q", outPath)
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(struct {
+		Gameplay *bsputil.Gameplay `json:"gameplay"`
+		Entities bsputil.Entities  `json:"entities"`
+	}{
+		Gameplay: gameplay,
+		Entities: entities,
+	})
+	if err != nil {
+		return errors.Wrapf(err, "failed to encode entities as JSON to output file %q", outPath)
+	}
+
+	return nil
+}
+
+func extractNavMesh(bspPath, outPath string, asJSON bool) error {
+	bspF, err := pathToBsp(bspPath)
+	if err != nil {
+		return errors.Wrap(err, "failed to read BSP data")
+	}
+
+	pakfile, err := bsputil.Pakfile(bspF)
+	if err != nil {
+		return errors.Wrap(err, "failed to read pakfile data")
+	}
+
+	var w io.Writer
+	if outPath == "-" {
+		w = os.Stdout
+	} else {
+		f, err := os.Create(outPath)
+		if err != nil {
+			return errors.Wrapf(err, "failed to create out file: %q", outPath)
+		}
+		defer f.Close()
+
+		w = f
+	}
+
+	if asJSON {
+		mesh, err := bsputil.GetNavMesh(pakfile)
+		if err != nil {
+			return errors.Wrap(err, "failed to get nav mesh from pakfile")
+		}
+
+		err = json.NewEncoder(w).Encode(mesh)
+		if err != nil {
+			return errors.Wrapf(err, "failed to encode nav mesh as JSON to output file %q", outPath)
+		}
+
+		return nil
+	}
+
+	r, err := bsputil.GetNavMeshFile(pakfile)
+	if err != nil {
+		return errors.Wrap(err, "failed to get nav mesh from pakfile")
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return errors.Wrap(err, "failed to extract/copy nav mesh data")
+	}
+
+	return nil
+}
+
+// renderProgress returns a steamapi.ProgressFunc that prints the download progress to w.
+func renderProgress(w io.Writer) steamapi.ProgressFunc {
+	const mib = 1 << 20
+
+	lastPercent := int64(-1)
+
+	return func(written, total int64) {
+		if total <= 0 {
+			fmt.Fprintf(w, "\rdownloaded %.1f MiB", float64(written)/mib)
+			return
+		}
+
+		percent := written * 100 / total
+		if percent == lastPercent {
+			return
+		}
+
+		lastPercent = percent
+
+		fmt.Fprintf(w, "\rdownloaded %.1f / %.1f MiB (%d%%)", float64(written)/mib, float64(total)/mib, percent)
+
+		if written >= total {
+			fmt.Fprintln(w)
+		}
+	}
+}
+
+func download(client *steamapi.Client, workshopFileID int, outPath string, timeout time.Duration, showProgress, extractBSP bool) error {
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	if extractBSP {
+		return downloadBSP(ctx, client, workshopFileID, outPath)
+	}
+
+	var err error
 
+	if outPath == "-" {
+		err = client.DownloadWorkshopItemContext(ctx, workshopFileID, os.Stdout)
+	} else {
+		var progress steamapi.ProgressFunc
+		if showProgress {
+			progress = renderProgress(os.Stderr)
+		}
+
+		// resumes from <out-file>.part if a previous download was interrupted
+		err = client.DownloadWorkshopItemToFile(ctx, workshopFileID, outPath, progress)
+	}
+
+	if err != nil {
+		return describeDownloadError(err, workshopFileID, outPath != "-")
+	}
+
+	return nil
+}
+
+func downloadBSP(ctx context.Context, client *steamapi.Client, workshopFileID int, outPath string) error {
+	var w io.Writer
 	if outPath == "-" {
 		w = os.Stdout
 	} else {
@@ -158,18 +509,56 @@ func download(workshopFileID int, outPath string) error {
 		w = f
 	}
 
-	err = steamapi.DownloadWorkshopItem(workshopFileID, w)
+	err := bsputil.DownloadWorkshopBSP(ctx, client, workshopFileID, w)
 	if err != nil {
-		return errors.Wrapf(err, "failed to download workshop item with ID %q", workshopFileID)
+		if outPath != "-" {
+			os.Remove(outPath)
+		}
+
+		return describeDownloadError(err, workshopFileID, false)
 	}
 
 	return nil
 }
 
+// describeDownloadError turns Steam API errors into messages that tell the user what went wrong.
+// resumable is set if the download was written to a .part file that the next attempt resumes from.
+func describeDownloadError(err error, workshopFileID int, resumable bool) error {
+	var (
+		bannedErr *steamapi.BannedError
+		statusErr *steamapi.HTTPStatusError
+		resultErr *steamapi.ResultError
+		sizeErr   *steamapi.SizeMismatchError
+	)
+
+	switch {
+	case errors.As(err, &bannedErr):
+		return errors.Wrapf(err, "workshop item %d has been banned (reason: %q)", workshopFileID, bannedErr.Reason)
+	case errors.Is(err, steamapi.ErrItemNotFound):
+		return errors.Wrapf(err, "workshop item %d does not exist or has been removed", workshopFileID)
+	case errors.Is(err, steamapi.ErrAccessDenied):
+		return errors.Wrapf(err, "workshop item %d is not publicly accessible", workshopFileID)
+	case errors.Is(err, steamapi.ErrItemBanned):
+		return errors.Wrapf(err, "workshop item %d has been banned", workshopFileID)
+	case errors.As(err, &statusErr):
+		return errors.Wrapf(err, "Steam responded with HTTP status %d while downloading workshop item %d", statusErr.StatusCode, workshopFileID)
+	case errors.As(err, &resultErr):
+		return errors.Wrapf(err, "Steam API returned error result %d for workshop item %d", resultErr.Result, workshopFileID)
+	case errors.As(err, &sizeErr) && resumable:
+		return errors.Wrapf(err, "download of workshop item %d is incomplete, run the command again to resume", workshopFileID)
+	case errors.As(err, &sizeErr):
+		return errors.Wrapf(err, "download of workshop item %d is incomplete, please retry", workshopFileID)
+	case errors.Is(err, context.DeadlineExceeded):
+		return errors.Wrapf(err, "download of workshop item %d timed out", workshopFileID)
+	}
+
+	return errors.Wrapf(err, "failed to download workshop item with ID %d", workshopFileID)
+}
+
 func extractCRCTable(engineClientSOPath, outPath string) error {
 	r, err := os.Open(engineClientSOPath)
 	if err != nil {
-		return errors.Wrapf(err, "failed to open enginge_client.so file %q", engineClientSOPath)
+		return errors.Wrapf(err, "failed to open engine binary %q", engineClientSOPath)
 	}
 
 	var w io.Writer
@@ -185,13 +574,19 @@ func extractCRCTable(engineClientSOPath, outPath string) error {
 		w = f
 	}
 
-	tab, err := crc.ExtractCRCTable(r)
+	res, err := crc.Extract(r)
 	if err != nil {
 		fmt.Printf("%+v\n", err)
-		return errors.Wrapf(err, "failed to extract CRC table from engine_client.so file %q", engineClientSOPath)
+		return errors.Wrapf(err, "failed to extract CRC table from engine binary %q", engineClientSOPath)
 	}
 
-	err = json.NewEncoder(w).Encode(tab)
+	log.Printf("extracted %d CRC table entries from %q using strategy %s", len(res.Entries), engineClientSOPath, res.Strategy)
+
+	for _, w := range res.Warnings {
+		log.Printf("warning: suspicious CRC table entry, %s", w)
+	}
+
+	err = json.NewEncoder(w).Encode(res.Entries)
 	if err != nil {
 		return errors.Wrapf(err, "failed to encode CRC table as JSON to output file %q", outPath)
 	}
@@ -199,6 +594,313 @@ func extractCRCTable(engineClientSOPath, outPath string) error {
 	return nil
 }
 
+// loadCRCTable reads a CRC table JSON file, either the output of the crc-table command (`[]Entry`) or `{"maps": []Entry}`.
+// If path is an engine binary (engine_client.so or engine.dll) the table is extracted from it instead.
+func loadCRCTable(path string) (*crc.Table, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read CRC table file %q", path)
+	}
+
+	if crc.IsBinary(b) {
+		res, err := crc.Extract(bytes.NewReader(b))
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to extract CRC table from engine binary %q", path)
+		}
+
+		log.Printf("extracted %d CRC table entries from %q using strategy %s", len(res.Entries), path, res.Strategy)
+
+		for _, w := range res.Warnings {
+			log.Printf("warning: suspicious CRC table entry, %s", w)
+		}
+
+		return crc.NewTable(res.Entries), nil
+	}
+
+	tab, err := crc.LoadTable(bytes.NewReader(b))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to load CRC table file %q", path)
+	}
+
+	return tab, nil
+}
+
+// lookupCRCTable prints all entries matching the given map CRC, map name and workshop ID as JSON.
+// Filters that aren't set (zero / empty) are ignored.
+func lookupCRCTable(crcTablePath string, mapCRC uint, mapName string, workshopID uint) error {
+	if mapCRC == 0 && mapName == "" && workshopID == 0 {
+		return errors.New("at least one of --map-crc, --map or --workshop-id is required")
+	}
+
+	tab, err := loadCRCTable(crcTablePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to load CRC table")
+	}
+
+	var candidates []crc.Entry
+
+	switch {
+	case mapCRC != 0:
+		if e, ok := tab.ByCRC(uint32(mapCRC)); ok {
+			candidates = []crc.Entry{e}
+		}
+	case mapName != "":
+		candidates = tab.ByMapName(mapName)
+	default:
+		candidates = tab.ByWorkshopID(uint32(workshopID))
+	}
+
+	res := make([]crc.Entry, 0, len(candidates))
+
+	for _, e := range candidates {
+		if (mapName == "" || e.MapName == mapName) && (workshopID == 0 || e.WorkshopID == uint32(workshopID)) {
+			res = append(res, e)
+		}
+	}
+
+	if len(res) == 0 {
+		return errors.New("no matching entries found in CRC table")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+
+	err = enc.Encode(res)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode entries as JSON")
+	}
+
+	return nil
+}
+
+// mergeCRCTables merges CRC tables (engine_client.so / engine.dll files, CRC table JSON files or other databases) into the database at dbPath.
+// Tables without build info are recorded with the given build ID and time, defaulting to a hash and the modification time of the input file.
+func mergeCRCTables(dbPath string, inputs []string, buildID, buildTime string) error {
+	if len(inputs) == 0 {
+		return errors.New("no input files given")
+	}
+
+	db := new(crcdb.DB)
+
+	f, err := os.Open(dbPath)
+	if err == nil {
+		db, err = crcdb.Load(f)
+		f.Close()
+
+		if err != nil {
+			return errors.Wrapf(err, "failed to load CRC database %q", dbPath)
+		}
+	} else if !os.IsNotExist(err) {
+		return errors.Wrapf(err, "failed to open CRC database %q", dbPath)
+	}
+
+	before := len(db.Maps)
+
+	for _, in := range inputs {
+		err = mergeCRCTable(db, in, buildID, buildTime)
+		if err != nil {
+			return errors.Wrapf(err, "failed to merge %q", in)
+		}
+	}
+
+	f, err = os.Create(dbPath)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create CRC database %q", dbPath)
+	}
+	defer f.Close()
+
+	err = db.Save(f)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write CRC database %q", dbPath)
+	}
+
+	log.Printf("%d map versions in %q (%d new)", len(db.Maps), dbPath, len(db.Maps)-before)
+
+	return nil
+}
+
+func mergeCRCTable(db *crcdb.DB, path, buildID, buildTime string) error {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to read file %q", path)
+	}
+
+	if !crc.IsBinary(b) {
+		other, err := crcdb.Load(bytes.NewReader(b))
+		if err == nil && len(other.Maps) > 0 && other.Maps[0].FirstSeen.ID != "" {
+			db.Merge(other)
+
+			return nil
+		}
+	}
+
+	tab, err := loadCRCTable(path)
+	if err != nil {
+		return err
+	}
+
+	build := crcdb.Build{ID: buildID}
+
+	if build.ID == "" {
+		sum := sha256.Sum256(b)
+		build.ID = hex.EncodeToString(sum[:6])
+	}
+
+	if buildTime != "" {
+		build.Time, err = time.Parse(time.RFC3339, buildTime)
+		if err != nil {
+			return errors.Wrapf(err, "invalid build time %q, must be RFC3339 (e.g. 2021-12-01T00:00:00Z)", buildTime)
+		}
+	} else {
+		stat, err := os.Stat(path)
+		if err != nil {
+			return errors.Wrapf(err, "failed to stat file %q", path)
+		}
+
+		build.Time = stat.ModTime().UTC().Truncate(time.Second)
+	}
+
+	db.Add(build, tab.Entries())
+
+	return nil
+}
+
+// resolve looks up a map CRC in the CRC table and extracts the radar assets of the map to <outDir>/<map>/<crc>/.
+func resolve(client *steamapi.Client, crcTablePath string, mapCRC uint, outDir string, timeout time.Duration) error {
+	tab, err := loadCRCTable(crcTablePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to load CRC table")
+	}
+
+	entry, ok := tab.ByCRC(uint32(mapCRC))
+	if !ok {
+		return errors.Errorf("map CRC %d not found in CRC table %q", mapCRC, crcTablePath)
+	}
+
+	ctx := context.Background()
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	dir := filepath.Join(outDir, entry.MapName, fmt.Sprint(entry.MapCrc32))
+
+	err = bsputil.ExtractWorkshopAssets(ctx, client, entry, dir)
+	if err != nil {
+		return errors.Wrapf(describeDownloadError(err, int(entry.WorkshopID), false), "failed to resolve map %q with CRC %d", entry.MapName, entry.MapCrc32)
+	}
+
+	fmt.Println(dir)
+
+	return nil
+}
+
+// verifyMapCRC checks that a BSP has the map CRC the CRC table claims for it.
+// If bspPath is empty, the workshop item of the map version is downloaded and verified instead.
+func verifyMapCRC(client *steamapi.Client, crcTablePath string, mapCRC uint, bspPath string, timeout time.Duration) error {
+	tab, err := loadCRCTable(crcTablePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to load CRC table")
+	}
+
+	entry, ok := tab.ByCRC(uint32(mapCRC))
+	if !ok {
+		return errors.Errorf("map CRC %d not found in CRC table %q", mapCRC, crcTablePath)
+	}
+
+	var bspF *bsp.Bsp
+
+	if bspPath == "" {
+		ctx := context.Background()
+		if timeout > 0 {
+			var cancel context.CancelFunc
+			ctx, cancel = context.WithTimeout(ctx, timeout)
+			defer cancel()
+		}
+
+		bspF, err = bsputil.GetWorkshopBSP(ctx, client, int(entry.WorkshopID))
+		if err != nil {
+			return describeDownloadError(err, int(entry.WorkshopID), false)
+		}
+	} else {
+		bspF, err = pathToBsp(bspPath)
+		if err != nil {
+			return errors.Wrap(err, "failed to read BSP data")
+		}
+	}
+
+	err = bsputil.VerifyBSP(bspF, entry)
+	if err != nil {
+		return errors.Wrapf(err, "map %q with CRC %d failed verification", entry.MapName, entry.MapCrc32)
+	}
+
+	fmt.Printf("ok: %s (workshop item %d) has map CRC %d\n", entry.MapName, entry.WorkshopID, entry.MapCrc32)
+
+	return nil
+}
+
+func mirrorCRCTable(client *steamapi.Client, crcTablePath, outDir, manifestPath string, workers int) error {
+	tab, err := loadCRCTable(crcTablePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to load CRC table")
+	}
+
+	m := &mirror.Mirror{
+		Client:  client,
+		Workers: workers,
+		Logger:  log.Default(),
+	}
+
+	err = os.MkdirAll(outDir, 0777)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create out dir %q", outDir)
+	}
+
+	manifest := m.Run(context.Background(), tab.Entries(), outDir)
+
+	if manifestPath == "" {
+		manifestPath = filepath.Join(outDir, "manifest.json")
+	}
+
+	b, err := json.MarshalIndent(manifest, "", "  ")
+	if err != nil {
+		return errors.Wrap(err, "failed to encode manifest as JSON")
+	}
+
+	err = ioutil.WriteFile(manifestPath, b, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write manifest %q", manifestPath)
+	}
+
+	log.Printf("mirrored %d map versions (%d already complete), %d failed - see %s", manifest.Succeeded, manifest.Skipped, manifest.Failed, manifestPath)
+
+	if manifest.Failed > 0 {
+		return errors.Errorf("failed to mirror %d map versions", manifest.Failed)
+	}
+
+	return nil
+}
+
+func serve(client *steamapi.Client, crcTablePath, addr, cacheDir string) error {
+	tab, err := loadCRCTable(crcTablePath)
+	if err != nil {
+		return errors.Wrap(err, "failed to load CRC table")
+	}
+
+	log.Printf("serving radar overviews for %d map versions on %s", tab.Len(), addr)
+
+	srv := server.New(tab, cacheDir)
+	srv.Download = client.DownloadWorkshopItemContext
+
+	err = http.ListenAndServe(addr, srv)
+	if err != nil {
+		return errors.Wrap(err, "failed to serve HTTP")
+	}
+
+	return nil
+}
+
 func lsVPK(prefix string) error {
 	vpkOpener := vpk.MultiVPK(prefix)
 
@@ -288,8 +990,38 @@ func main() {
 			Usage:       "Output directory to which to save the data",
 			Destination: &outDir,
 		}
-		workshopFileID int
-		prefixFilter   string
+		workshopFileID  int
+		downloadTimeout time.Duration
+		showProgress    bool
+		extractBSP      bool
+		prefixFilter    string
+		imageFormat     string
+		mipMaps         bool
+		asJSON          bool
+		crcTableFile    string
+		crcTableFlag    = &cli.StringFlag{
+			Name:        "crc-table",
+			Usage:       "CRC table JSON file as produced by the crc-table command, or bin/linux64/engine_client.so / bin/engine.dll",
+			Required:    true,
+			Destination: &crcTableFile,
+		}
+		mapCRC       uint
+		crcDBFile    string
+		buildID      string
+		buildTime    string
+		mapName      string
+		workshopID   uint
+		manifestFile string
+		workers      int
+		listenAddr   string
+		cacheDir     string
+		steamClient  = steamapi.NewClient()
+		steamAPIFlag = &cli.StringFlag{
+			Name:        "steam-api-url",
+			Value:       steamapi.DefaultBaseURL,
+			Usage:       "Base URL of the Steam Web API",
+			Destination: &steamClient.BaseURL,
+		}
 	)
 
 	app := &cli.App{
@@ -299,11 +1031,64 @@ func main() {
 			{
 				Name:    "crc-table",
 				Aliases: []string{"crc"},
-				Usage:   "extract the CRC table from bin/linux64/engine_client.so",
+				Usage:   "extract the CRC table from bin/linux64/engine_client.so or bin/engine.dll",
 				Flags:   []cli.Flag{inFileFlag, outFileFlag},
 				Action: func(c *cli.Context) error {
 					return extractCRCTable(inFile, outFile)
 				},
+				Subcommands: []*cli.Command{
+					{
+						Name:  "lookup",
+						Usage: "look up map versions in a CRC table by map CRC, map name and/or workshop ID",
+						Flags: []cli.Flag{
+							crcTableFlag,
+							&cli.UintFlag{
+								Name:        "map-crc",
+								Usage:       "Map CRC to look up",
+								Destination: &mapCRC,
+							},
+							&cli.StringFlag{
+								Name:        "map",
+								Usage:       "Map name to look up, returns all versions of the map",
+								Destination: &mapName,
+							},
+							&cli.UintFlag{
+								Name:        "workshop-id",
+								Usage:       "Workshop ID to look up",
+								Destination: &workshopID,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return lookupCRCTable(crcTableFile, mapCRC, mapName, workshopID)
+						},
+					},
+					{
+						Name:      "merge",
+						Usage:     "merge CRC tables of many builds (engine_client.so, engine.dll or CRC table JSON files) into a CRC database that keeps old map versions",
+						ArgsUsage: "<engine_client.so|engine.dll|crc_table.json>...",
+						Flags: []cli.Flag{
+							&cli.StringFlag{
+								Name:        "db",
+								Value:       "crc_db.json",
+								Usage:       "CRC database file to merge into, created if it doesn't exist",
+								Destination: &crcDBFile,
+							},
+							&cli.StringFlag{
+								Name:        "build-id",
+								Usage:       "Build ID to record for the input tables, e.g. the PatchVersion from csgo/steam.inf (default: hash of the input file)",
+								Destination: &buildID,
+							},
+							&cli.StringFlag{
+								Name:        "build-time",
+								Usage:       "Build time (RFC3339) to record for the input tables (default: modification time of the input file)",
+								Destination: &buildTime,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return mergeCRCTables(crcDBFile, c.Args().Slice(), buildID, buildTime)
+						},
+					},
+				},
 			},
 			{
 				Name:  "bsp",
@@ -321,10 +1106,57 @@ func main() {
 					{
 						Name:    "radar-image",
 						Aliases: []string{"radar"},
-						Usage:   "extract radar overview image (.dds file) and the corresponding info (.txt file)",
-						Flags:   []cli.Flag{inFileFlag, outDirFlag},
+						Usage:   "extract all radar overview images (levels and spectator variants, .dds or .png files), the corresponding info (.txt file) and a manifest (.json file)",
+						Flags: []cli.Flag{
+							inFileFlag,
+							outDirFlag,
+							&cli.StringFlag{
+								Name:        "format",
+								Value:       "dds",
+								Usage:       "Image format of the extracted radar image (dds or png)",
+								Destination: &imageFormat,
+							},
+							&cli.BoolFlag{
+								Name:        "mipmaps",
+								Usage:       "Also write the mipmaps of the radar images as <image>_mip<level>.png (png format only)",
+								Destination: &mipMaps,
+							},
+						},
 						Action: func(c *cli.Context) error {
-							return extractRadarOverview(inFile, outDir)
+							return extractRadarOverview(inFile, outDir, imageFormat, mipMaps)
+						},
+					},
+					{
+						Name:  "nav",
+						Usage: "extract the nav mesh (.nav file), optionally parsed as JSON",
+						Flags: []cli.Flag{
+							inFileFlag,
+							outFileFlag,
+							&cli.BoolFlag{
+								Name:        "json",
+								Usage:       "Parse the nav mesh and output it as JSON instead of the raw .nav file",
+								Destination: &asJSON,
+							},
+						},
+						Action: func(c *cli.Context) error {
+							return extractNavMesh(inFile, outFile, asJSON)
+						},
+					},
+					{
+						Name:  "info",
+						Usage: "print metadata of a .bsp file as JSON (version, CRC32, lumps, pakfile, map name, available radar assets and radar info)",
+						Flags: []cli.Flag{inFileFlag, outFileFlag},
+						Action: func(c *cli.Context) error {
+							return bspInfo(inFile, outFile)
+						},
+					},
+					{
+						Name:    "entities",
+						Aliases: []string{"ent"},
+						Usage:   "parse the entity lump and print spawns, bombsites, buy zones, hostages and all other entities as JSON",
+						Flags:   []cli.Flag{inFileFlag, outFileFlag},
+						Action: func(c *cli.Context) error {
+							return bspEntities(inFile, outFile)
 						},
 					},
 					{
@@ -381,9 +1213,127 @@ func main() {
 						Destination: &workshopFileID,
 					},
 					outFileFlag,
+					steamAPIFlag,
+					&cli.DurationFlag{
+						Name:        "timeout",
+						Value:       0,
+						Usage:       "Abort the download after this duration (e.g. 5m), 0 means no timeout",
+						Destination: &downloadTimeout,
+					},
+					&cli.BoolFlag{
+						Name:        "progress",
+						Value:       true,
+						Usage:       "Show download progress on stderr when writing to a file",
+						Destination: &showProgress,
+					},
+					&cli.BoolFlag{
+						Name:        "extract-bsp",
+						Aliases:     []string{"bsp"},
+						Usage:       "Unpack the workshop zip and write the contained .bsp file instead of the zip",
+						Destination: &extractBSP,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return download(steamClient, workshopFileID, outFile, downloadTimeout, showProgress, extractBSP)
+				},
+			},
+			{
+				Name:  "mirror",
+				Usage: "download the radar assets of all map versions in a CRC table to <out-dir>/<map>/<crc>/ and write a manifest",
+				Flags: []cli.Flag{
+					crcTableFlag,
+					outDirFlag,
+					&cli.StringFlag{
+						Name:        "manifest",
+						Usage:       "Manifest file summarizing successes and failures (default: <out-dir>/manifest.json)",
+						Destination: &manifestFile,
+					},
+					&cli.IntFlag{
+						Name:        "workers",
+						Value:       mirror.DefaultWorkers,
+						Usage:       "Number of workshop items to download concurrently",
+						Destination: &workers,
+					},
+					steamAPIFlag,
+				},
+				Action: func(c *cli.Context) error {
+					return mirrorCRCTable(steamClient, crcTableFile, outDir, manifestFile, workers)
+				},
+			},
+			{
+				Name:  "resolve",
+				Usage: "download the workshop map for a map CRC (e.g. from a demo) and extract its radar assets to <out-dir>/<map>/<crc>/",
+				Flags: []cli.Flag{
+					&cli.UintFlag{
+						Name:        "map-crc",
+						Usage:       "Map CRC to resolve",
+						Required:    true,
+						Destination: &mapCRC,
+					},
+					crcTableFlag,
+					outDirFlag,
+					steamAPIFlag,
+					&cli.DurationFlag{
+						Name:        "timeout",
+						Value:       0,
+						Usage:       "Abort the download after this duration (e.g. 5m), 0 means no timeout",
+						Destination: &downloadTimeout,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return resolve(steamClient, crcTableFile, mapCRC, outDir, downloadTimeout)
+				},
+			},
+			{
+				Name:  "verify",
+				Usage: "check that a BSP (or the workshop item of a map version) has the map CRC the CRC table expects",
+				Flags: []cli.Flag{
+					&cli.UintFlag{
+						Name:        "map-crc",
+						Usage:       "Map CRC of the map version to verify",
+						Required:    true,
+						Destination: &mapCRC,
+					},
+					crcTableFlag,
+					&cli.StringFlag{
+						Name:        "in-file",
+						Aliases:     []string{"i"},
+						Usage:       "BSP file to verify, - for stdin (default: download the workshop item of the map version)",
+						Destination: &inFile,
+					},
+					steamAPIFlag,
+					&cli.DurationFlag{
+						Name:        "timeout",
+						Value:       0,
+						Usage:       "Abort the download after this duration (e.g. 5m), 0 means no timeout",
+						Destination: &downloadTimeout,
+					},
+				},
+				Action: func(c *cli.Context) error {
+					return verifyMapCRC(steamClient, crcTableFile, mapCRC, inFile, downloadTimeout)
+				},
+			},
+			{
+				Name:  "serve",
+				Usage: "serve radar overviews via HTTP like radar-overviews.csgo.saiko.tech (/<map>/<crc>/radar.dds|radar.png|info.txt|info.json|nav.nav)",
+				Flags: []cli.Flag{
+					crcTableFlag,
+					&cli.StringFlag{
+						Name:        "listen",
+						Value:       ":8080",
+						Usage:       "Address to listen on",
+						Destination: &listenAddr,
+					},
+					&cli.StringFlag{
+						Name:        "cache-dir",
+						Value:       "cache",
+						Usage:       "Directory in which to cache extracted assets",
+						Destination: &cacheDir,
+					},
+					steamAPIFlag,
 				},
 				Action: func(c *cli.Context) error {
-					return download(workshopFileID, outFile)
+					return serve(steamClient, crcTableFile, listenAddr, cacheDir)
 				},
 			},
 		},
@@ -0,0 +1,111 @@
+package fixture
+
+import (
+	"bytes"
+	"debug/elf"
+	"encoding/binary"
+)
+
+// Section is a section of a fixture binary.
+type Section struct {
+	Name string
+	// Addr is the virtual address for ELF and the address relative to the image base (RVA) for PE binaries.
+	Addr uint64
+	Data []byte
+	// Exec marks the section as executable code.
+	Exec bool
+}
+
+// ELF returns the bytes of a minimal x86-64 ELF shared object containing the given sections.
+// Section data is laid out with padding between sections so file offsets never equal virtual addresses.
+func ELF(sections ...Section) []byte {
+	const (
+		headerSize        = 64
+		sectionHeaderSize = 64
+		padding           = 0x30
+	)
+
+	shstrtab := []byte{0}
+	nameOffsets := make([]uint32, len(sections))
+
+	for i, s := range sections {
+		nameOffsets[i] = uint32(len(shstrtab))
+		shstrtab = append(append(shstrtab, s.Name...), 0)
+	}
+
+	shstrtabName := uint32(len(shstrtab))
+	shstrtab = append(shstrtab, ".shstrtab\x00"...)
+
+	var (
+		data    bytes.Buffer
+		headers = []elf.Section64{{}}
+		offset  = uint64(headerSize)
+	)
+
+	for i, s := range sections {
+		data.Write(make([]byte, padding))
+		offset += padding
+
+		flags := elf.SHF_ALLOC
+		if s.Exec {
+			flags |= elf.SHF_EXECINSTR
+		}
+
+		headers = append(headers, elf.Section64{
+			Name:      nameOffsets[i],
+			Type:      uint32(elf.SHT_PROGBITS),
+			Flags:     uint64(flags),
+			Addr:      s.Addr,
+			Off:       offset,
+			Size:      uint64(len(s.Data)),
+			Addralign: 1,
+		})
+
+		data.Write(s.Data)
+		offset += uint64(len(s.Data))
+	}
+
+	headers = append(headers, elf.Section64{
+		Name:      shstrtabName,
+		Type:      uint32(elf.SHT_STRTAB),
+		Off:       offset,
+		Size:      uint64(len(shstrtab)),
+		Addralign: 1,
+	})
+
+	data.Write(shstrtab)
+	offset += uint64(len(shstrtab))
+
+	header := elf.Header64{
+		Type:      uint16(elf.ET_DYN),
+		Machine:   uint16(elf.EM_X86_64),
+		Version:   uint32(elf.EV_CURRENT),
+		Shoff:     offset,
+		Ehsize:    headerSize,
+		Phentsize: 56,
+		Shentsize: sectionHeaderSize,
+		Shnum:     uint16(len(headers)),
+		Shstrndx:  uint16(len(headers) - 1),
+	}
+
+	copy(header.Ident[:], elf.ELFMAG)
+	header.Ident[elf.EI_CLASS] = byte(elf.ELFCLASS64)
+	header.Ident[elf.EI_DATA] = byte(elf.ELFDATA2LSB)
+	header.Ident[elf.EI_VERSION] = byte(elf.EV_CURRENT)
+
+	var buf bytes.Buffer
+
+	err := binary.Write(&buf, binary.LittleEndian, header)
+	if err != nil {
+		panic(err)
+	}
+
+	buf.Write(data.Bytes())
+
+	err = binary.Write(&buf, binary.LittleEndian, headers)
+	if err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
@@ -0,0 +1,136 @@
+// Package fixture builds in-memory BSP, zip and binary (ELF / PE) files for tests.
+package fixture
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/binary"
+	"sort"
+
+	"github.com/galaco/bsp"
+)
+
+const (
+	bspVersion    = 21
+	bspHeaderSize = 4 + 4 + 64*16 + 4
+)
+
+// BSP returns the bytes of a minimal BSP file containing the given lumps.
+func BSP(lumps map[bsp.LumpId][]byte) []byte {
+	type headerLump struct {
+		Offset, Length, Version int32
+		ID                      [4]byte
+	}
+
+	var (
+		header struct {
+			ID       [4]byte
+			Version  int32
+			Lumps    [64]headerLump
+			Revision int32
+		}
+		data   bytes.Buffer
+		offset = int32(bspHeaderSize)
+	)
+
+	copy(header.ID[:], "VBSP")
+	header.Version = bspVersion
+	header.Revision = 1
+
+	for id := bsp.LumpId(0); id < 64; id++ {
+		b := lumps[id]
+
+		header.Lumps[id] = headerLump{Offset: offset, Length: int32(len(b))}
+		data.Write(b)
+		offset += int32(len(b))
+	}
+
+	var buf bytes.Buffer
+
+	err := binary.Write(&buf, binary.LittleEndian, header)
+	if err != nil {
+		panic(err)
+	}
+
+	buf.Write(data.Bytes())
+
+	return buf.Bytes()
+}
+
+// Zip returns the bytes of a zip archive containing the given files.
+func Zip(files map[string][]byte) []byte {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+
+	for _, name := range names {
+		f, err := w.Create(name)
+		if err != nil {
+			panic(err)
+		}
+
+		_, err = f.Write(files[name])
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	err := w.Close()
+	if err != nil {
+		panic(err)
+	}
+
+	return buf.Bytes()
+}
+
+// MapBSP returns a BSP file with a pakfile containing the given files.
+func MapBSP(pakfile map[string][]byte) []byte {
+	return BSP(map[bsp.LumpId][]byte{
+		bsp.LumpPakfile: Zip(pakfile),
+	})
+}
+
+// DDS returns the bytes of a 4x4 DXT1 compressed DDS image, with a red top row and blue below.
+func DDS() []byte {
+	var buf bytes.Buffer
+
+	buf.WriteString("DDS ")
+
+	header := make([]uint32, 31)
+	header[0] = 124                                         // size
+	header[2], header[3] = 4, 4                             // height, width
+	header[18] = 32                                         // pixel format size
+	header[19] = 0x4                                        // DDPF_FOURCC
+	header[20] = binary.LittleEndian.Uint32([]byte("DXT1")) // FourCC
+
+	err := binary.Write(&buf, binary.LittleEndian, header)
+	if err != nil {
+		panic(err)
+	}
+
+	buf.Write([]byte{0x00, 0xf8, 0x1f, 0x00, 0x00, 0x55, 0x55, 0x55})
+
+	return buf.Bytes()
+}
+
+// MapCRC returns the map CRC of a BSP file as calculated by the engine, for building CRC tables that match fixture maps.
+func MapCRC(b []byte) uint32 {
+	f, err := bsp.ReadFromStream(bytes.NewReader(b))
+	if err != nil {
+		panic(err)
+	}
+
+	crc, err := f.CRC32()
+	if err != nil {
+		panic(err)
+	}
+
+	return crc
+}
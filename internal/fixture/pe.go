@@ -0,0 +1,101 @@
+package fixture
+
+import (
+	"bytes"
+	"debug/pe"
+	"encoding/binary"
+)
+
+// PE returns the bytes of a minimal 32-bit x86 PE (Windows DLL) containing the given sections.
+// Section addresses are relative to imageBase (RVAs), raw data is aligned to 0x200 bytes like in real binaries.
+func PE(imageBase uint32, sections ...Section) []byte {
+	const (
+		peOffset       = 0x40
+		fileAlignment  = 0x200
+		sectionAlign   = 0x1000
+		optHeaderSize  = 224
+		sectionHdrSize = 40
+	)
+
+	align := func(n, a uint32) uint32 {
+		return (n + a - 1) / a * a
+	}
+
+	headersSize := align(peOffset+4+20+optHeaderSize+uint32(len(sections))*sectionHdrSize, fileAlignment)
+
+	var (
+		headers []pe.SectionHeader32
+		data    bytes.Buffer
+		offset  = headersSize
+	)
+
+	for _, s := range sections {
+		var name [8]uint8
+		copy(name[:], s.Name)
+
+		characteristics := uint32(pe.IMAGE_SCN_CNT_INITIALIZED_DATA | pe.IMAGE_SCN_MEM_READ)
+		if s.Exec {
+			characteristics = pe.IMAGE_SCN_CNT_CODE | pe.IMAGE_SCN_MEM_EXECUTE | pe.IMAGE_SCN_MEM_READ
+		}
+
+		rawSize := align(uint32(len(s.Data)), fileAlignment)
+
+		headers = append(headers, pe.SectionHeader32{
+			Name:             name,
+			VirtualSize:      uint32(len(s.Data)),
+			VirtualAddress:   uint32(s.Addr),
+			SizeOfRawData:    rawSize,
+			PointerToRawData: offset,
+			Characteristics:  characteristics,
+		})
+
+		data.Write(s.Data)
+		data.Write(make([]byte, rawSize-uint32(len(s.Data))))
+		offset += rawSize
+	}
+
+	var sizeOfImage uint32
+	for _, h := range headers {
+		if end := align(h.VirtualAddress+h.VirtualSize, sectionAlign); end > sizeOfImage {
+			sizeOfImage = end
+		}
+	}
+
+	var buf bytes.Buffer
+
+	dosHeader := make([]byte, peOffset)
+	copy(dosHeader, "MZ")
+	binary.LittleEndian.PutUint32(dosHeader[0x3c:], peOffset)
+
+	buf.Write(dosHeader)
+	buf.WriteString("PE\x00\x00")
+
+	for _, v := range []interface{}{
+		pe.FileHeader{
+			Machine:              pe.IMAGE_FILE_MACHINE_I386,
+			NumberOfSections:     uint16(len(sections)),
+			SizeOfOptionalHeader: optHeaderSize,
+			Characteristics:      pe.IMAGE_FILE_EXECUTABLE_IMAGE | pe.IMAGE_FILE_32BIT_MACHINE | pe.IMAGE_FILE_DLL,
+		},
+		pe.OptionalHeader32{
+			Magic:               0x10b,
+			ImageBase:           imageBase,
+			SectionAlignment:    sectionAlign,
+			FileAlignment:       fileAlignment,
+			SizeOfImage:         sizeOfImage,
+			SizeOfHeaders:       headersSize,
+			NumberOfRvaAndSizes: 16,
+		},
+		headers,
+	} {
+		err := binary.Write(&buf, binary.LittleEndian, v)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	buf.Write(make([]byte, int(headersSize)-buf.Len()))
+	buf.Write(data.Bytes())
+
+	return buf.Bytes()
+}
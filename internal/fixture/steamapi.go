@@ -0,0 +1,157 @@
+package fixture
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// SteamAPI is a stand-in for the Steam Web API (GetPublishedFileDetails) and the workshop file CDN.
+type SteamAPI struct {
+	*httptest.Server
+
+	mu              sync.Mutex
+	items           map[int][]byte
+	downloads       map[int]int
+	ranges          []string
+	detailsRequests int
+}
+
+const resultOK, resultFileNotFound = 1, 9
+
+// NewSteamAPI starts a stand-in serving the given workshop items (by ID).
+// Close it when done.
+func NewSteamAPI(items map[int][]byte) *SteamAPI {
+	api := &SteamAPI{
+		items:     items,
+		downloads: make(map[int]int),
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ISteamRemoteStorage/GetPublishedFileDetails/v1", api.details)
+	mux.HandleFunc("/ugc/", api.file)
+
+	api.Server = httptest.NewServer(mux)
+
+	return api
+}
+
+// Downloads returns how often the file of a workshop item has been requested.
+func (api *SteamAPI) Downloads(workshopID int) int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return api.downloads[workshopID]
+}
+
+// Ranges returns the Range headers of all file requests that had one.
+func (api *SteamAPI) Ranges() []string {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return append([]string(nil), api.ranges...)
+}
+
+// DetailsRequests returns how many GetPublishedFileDetails requests have been made.
+func (api *SteamAPI) DetailsRequests() int {
+	api.mu.Lock()
+	defer api.mu.Unlock()
+
+	return api.detailsRequests
+}
+
+func (api *SteamAPI) details(w http.ResponseWriter, r *http.Request) {
+	api.mu.Lock()
+	api.detailsRequests++
+	api.mu.Unlock()
+
+	if r.Method != http.MethodPost {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	err := r.ParseForm()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	n, err := strconv.Atoi(r.PostForm.Get("itemcount"))
+	if err != nil {
+		http.Error(w, "invalid itemcount", http.StatusBadRequest)
+		return
+	}
+
+	details := make([]map[string]interface{}, 0, n)
+
+	for i := 0; i < n; i++ {
+		idStr := r.PostForm.Get(fmt.Sprintf("publishedfileids[%d]", i))
+
+		id, err := strconv.Atoi(idStr)
+		if err != nil {
+			http.Error(w, "invalid publishedfileid", http.StatusBadRequest)
+			return
+		}
+
+		b, ok := api.items[id]
+		if !ok {
+			details = append(details, map[string]interface{}{
+				"publishedfileid": idStr,
+				"result":          resultFileNotFound,
+			})
+
+			continue
+		}
+
+		details = append(details, map[string]interface{}{
+			"publishedfileid": idStr,
+			"result":          resultOK,
+			"consumer_app_id": 730,
+			"filename":        fmt.Sprintf("mymaps/%d.bsp.zip", id),
+			"file_size":       len(b),
+			"file_url":        fmt.Sprintf("%s/ugc/%d/", api.URL, id),
+			"title":           fmt.Sprintf("workshop item %d", id),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+
+	_ = json.NewEncoder(w).Encode(map[string]interface{}{
+		"response": map[string]interface{}{
+			"result":               resultOK,
+			"resultcount":          len(details),
+			"publishedfiledetails": details,
+		},
+	})
+}
+
+func (api *SteamAPI) file(w http.ResponseWriter, r *http.Request) {
+	id, err := strconv.Atoi(strings.Trim(strings.TrimPrefix(r.URL.Path, "/ugc/"), "/"))
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	b, ok := api.items[id]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	api.mu.Lock()
+	api.downloads[id]++
+
+	if rng := r.Header.Get("Range"); rng != "" {
+		api.ranges = append(api.ranges, rng)
+	}
+
+	api.mu.Unlock()
+
+	http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(b))
+}
@@ -0,0 +1,252 @@
+package bsputil
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"image/png"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+
+	"github.com/galaco/bsp"
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/vdf"
+)
+
+// File names of the assets written by ExtractAssets.
+const (
+	AssetRadarDDS = "radar.dds"
+	AssetRadarPNG = "radar.png"
+	AssetInfoTxt  = "info.txt"
+	AssetInfoJSON = "info.json"
+	AssetNav      = "nav.nav"
+
+	// AssetsCompleteMarker is written to an asset dir once all available assets have been extracted.
+	AssetsCompleteMarker = ".complete"
+)
+
+// ExtractAssets extracts all available radar assets (radar.dds, radar.png, info.txt, info.json and nav.nav) from a pakfile to dir.
+// Assets that the map doesn't contain are skipped.
+func ExtractAssets(pakfile *zip.Reader, dir string) error {
+	err := os.MkdirAll(dir, 0777)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %q", dir)
+	}
+
+	infoR, imgR, err := GetRadarImage(pakfile)
+	if err != nil && !errors.Is(err, ErrRadarImageNotFound) {
+		return errors.Wrap(err, "failed to get radar image")
+	}
+
+	if err == nil {
+		err = extractRadar(infoR, imgR, dir)
+		if err != nil {
+			return err
+		}
+	}
+
+	navR, err := GetNavMeshFile(pakfile)
+	if errors.Is(err, ErrNavMeshNotFound) {
+		return nil
+	} else if err != nil {
+		return errors.Wrap(err, "failed to get nav mesh")
+	}
+	defer navR.Close()
+
+	return writeFile(filepath.Join(dir, AssetNav), navR)
+}
+
+// ExtractWorkshopAssets downloads the workshop item of entry and extracts the assets of the contained map to dir, see ExtractVerifiedAssets.
+func ExtractWorkshopAssets(ctx context.Context, client *steamapi.Client, entry crc.Entry, dir string) error {
+	bspF, err := GetWorkshopBSP(ctx, client, int(entry.WorkshopID))
+	if err != nil {
+		return err
+	}
+
+	return ExtractVerifiedAssets(bspF, entry, dir)
+}
+
+// ExtractVerifiedAssets verifies the map against entry first (see VerifyBSP) and then extracts its assets to dir (see ExtractAssets),
+// so the assets of a different map version are never extracted.
+func ExtractVerifiedAssets(f *bsp.Bsp, entry crc.Entry, dir string) error {
+	err := VerifyBSP(f, entry)
+	if err != nil {
+		return errors.Wrapf(err, "workshop item %d", entry.WorkshopID)
+	}
+
+	pakfile, err := Pakfile(f)
+	if err != nil {
+		return errors.Wrap(err, "failed to read pakfile data")
+	}
+
+	return ExtractAssets(pakfile, dir)
+}
+
+// ExtractAssetsAtomic is like ExtractVerifiedAssets for asset dirs of the <dir>/<map>/<crc>/ layout shared by the server and mirror packages.
+// The assets are extracted to a temp dir first, which replaces dir once it contains all assets and AssetsCompleteMarker,
+// so a failed extraction never leaves a half-populated dir behind.
+func ExtractAssetsAtomic(f *bsp.Bsp, entry crc.Entry, dir string) error {
+	err := os.MkdirAll(filepath.Dir(dir), 0777)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create dir %q", filepath.Dir(dir))
+	}
+
+	tmpDir, err := ioutil.TempDir(filepath.Dir(dir), ".tmp-")
+	if err != nil {
+		return errors.Wrap(err, "failed to create temp dir")
+	}
+	defer os.RemoveAll(tmpDir)
+
+	err = ExtractVerifiedAssets(f, entry, tmpDir)
+	if err != nil {
+		return err
+	}
+
+	err = ioutil.WriteFile(filepath.Join(tmpDir, AssetsCompleteMarker), nil, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to write completion marker")
+	}
+
+	err = os.RemoveAll(dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to remove incomplete dir %q", dir)
+	}
+
+	err = os.Rename(tmpDir, dir)
+	if err != nil {
+		return errors.Wrapf(err, "failed to move assets to %q", dir)
+	}
+
+	return nil
+}
+
+// AssetsComplete checks whether dir contains AssetsCompleteMarker, see ExtractAssetsAtomic.
+func AssetsComplete(dir string) bool {
+	_, err := os.Stat(filepath.Join(dir, AssetsCompleteMarker))
+
+	return err == nil
+}
+
+// crcMismatch is a cached CRCMismatchError, stored as <dir>.mismatch next to the asset dir of its entry.
+type crcMismatch struct {
+	Entry  crc.Entry `json:"entry"`
+	Actual uint32    `json:"actual_map_crc"`
+}
+
+func crcMismatchPath(dir string) string {
+	return filepath.Clean(dir) + ".mismatch"
+}
+
+// CachedCRCMismatch returns the mismatch cached for the asset dir of entry by CacheCRCMismatch,
+// so workshop items that contain a different version of the map aren't downloaded again.
+// Mismatches cached for a different entry (e.g. after the CRC table was updated) are ignored.
+func CachedCRCMismatch(dir string, entry crc.Entry) (*CRCMismatchError, bool) {
+	b, err := ioutil.ReadFile(crcMismatchPath(dir))
+	if err != nil {
+		return nil, false
+	}
+
+	var m crcMismatch
+
+	err = json.Unmarshal(b, &m)
+	if err != nil || m.Entry != entry {
+		return nil, false
+	}
+
+	return &CRCMismatchError{Entry: m.Entry, Actual: m.Actual}, true
+}
+
+// CacheCRCMismatch stores a mismatch for the asset dir of its entry, see CachedCRCMismatch.
+func CacheCRCMismatch(dir string, mismatchErr *CRCMismatchError) error {
+	b, err := json.Marshal(crcMismatch{Entry: mismatchErr.Entry, Actual: mismatchErr.Actual})
+	if err != nil {
+		return errors.Wrap(err, "failed to encode CRC mismatch as JSON")
+	}
+
+	err = ioutil.WriteFile(crcMismatchPath(dir), b, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write %q", crcMismatchPath(dir))
+	}
+
+	return nil
+}
+
+func extractRadar(infoR, imgR io.ReadCloser, dir string) error {
+	defer infoR.Close()
+	defer imgR.Close()
+
+	info, err := ioutil.ReadAll(infoR)
+	if err != nil {
+		return errors.Wrap(err, "failed to read radar info")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, AssetInfoTxt), info, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to write radar info")
+	}
+
+	kv, err := vdf.ParseBytes(info)
+	if err != nil {
+		return errors.Wrap(err, "failed to parse radar info")
+	}
+
+	infoJSON, err := json.Marshal(kv)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode radar info as JSON")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, AssetInfoJSON), infoJSON, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to write radar info JSON")
+	}
+
+	img, err := ioutil.ReadAll(imgR)
+	if err != nil {
+		return errors.Wrap(err, "failed to read radar image")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, AssetRadarDDS), img, 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to write radar image")
+	}
+
+	decoded, err := DecodeRadarImage(bytes.NewReader(img))
+	if err != nil {
+		return errors.Wrap(err, "failed to decode radar image")
+	}
+
+	var pngBuf bytes.Buffer
+
+	err = png.Encode(&pngBuf, decoded)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode radar image as PNG")
+	}
+
+	err = ioutil.WriteFile(filepath.Join(dir, AssetRadarPNG), pngBuf.Bytes(), 0666)
+	if err != nil {
+		return errors.Wrap(err, "failed to write radar PNG")
+	}
+
+	return nil
+}
+
+func writeFile(path string, r io.Reader) error {
+	f, err := os.Create(path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to create file %q", path)
+	}
+	defer f.Close()
+
+	_, err = io.Copy(f, r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to write file %q", path)
+	}
+
+	return nil
+}
@@ -0,0 +1,111 @@
+package bsputil_test
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/galaco/bsp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+func TestExtractWorkshopAssets(t *testing.T) {
+	const workshopID = 157233767
+
+	api := steamAPI(workshopID)
+	defer api.Close()
+
+	entry := crc.Entry{MapName: "de_nuke", MapCrc32: fixture.MapCRC(nukeBSP()), WorkshopID: workshopID}
+	dir := filepath.Join(t.TempDir(), "de_nuke", fmt.Sprint(entry.MapCrc32))
+
+	err := bsputil.ExtractWorkshopAssets(context.Background(), &steamapi.Client{BaseURL: api.URL}, entry, dir)
+	assert.NoError(t, err)
+
+	info, err := ioutil.ReadFile(filepath.Join(dir, bsputil.AssetInfoTxt))
+	assert.NoError(t, err)
+	assert.Equal(t, nukeRadarInfo, string(info))
+
+	infoJSON, err := ioutil.ReadFile(filepath.Join(dir, bsputil.AssetInfoJSON))
+	assert.NoError(t, err)
+	assert.Contains(t, string(infoJSON), `"de_nuke":{`)
+
+	for _, name := range []string{bsputil.AssetRadarDDS, bsputil.AssetRadarPNG} {
+		_, err = os.Stat(filepath.Join(dir, name))
+		assert.NoErrorf(t, err, "expected %s to be extracted", name)
+	}
+
+	// the fixture map has no nav mesh
+	_, err = os.Stat(filepath.Join(dir, bsputil.AssetNav))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestExtractWorkshopAssetsCRCMismatch(t *testing.T) {
+	const workshopID = 157233767
+
+	api := steamAPI(workshopID)
+	defer api.Close()
+
+	entry := crc.Entry{MapName: "de_nuke", MapCrc32: 3124679106, WorkshopID: workshopID}
+	dir := filepath.Join(t.TempDir(), "de_nuke", "3124679106")
+
+	err := bsputil.ExtractWorkshopAssets(context.Background(), &steamapi.Client{BaseURL: api.URL}, entry, dir)
+
+	var mismatch *bsputil.CRCMismatchError
+	if assert.True(t, errors.As(err, &mismatch)) {
+		assert.Equal(t, entry, mismatch.Entry)
+		assert.Equal(t, fixture.MapCRC(nukeBSP()), mismatch.Actual)
+	}
+
+	assert.NoDirExists(t, dir)
+}
+
+func TestExtractAssetsAtomic(t *testing.T) {
+	f, err := bsp.ReadFromStream(bytes.NewReader(nukeBSP()))
+	assert.NoError(t, err)
+
+	entry := crc.Entry{MapName: "de_nuke", MapCrc32: fixture.MapCRC(nukeBSP()), WorkshopID: 1}
+	dir := filepath.Join(t.TempDir(), "de_nuke", fmt.Sprint(entry.MapCrc32))
+
+	// leftovers of an interrupted extraction are replaced
+	assert.NoError(t, os.MkdirAll(dir, 0777))
+	assert.NoError(t, ioutil.WriteFile(filepath.Join(dir, "stale"), nil, 0666))
+	assert.False(t, bsputil.AssetsComplete(dir))
+
+	err = bsputil.ExtractAssetsAtomic(f, entry, dir)
+	assert.NoError(t, err)
+	assert.True(t, bsputil.AssetsComplete(dir))
+	assert.NoFileExists(t, filepath.Join(dir, "stale"))
+	assert.FileExists(t, filepath.Join(dir, bsputil.AssetRadarPNG))
+
+	other := crc.Entry{MapName: "de_nuke", MapCrc32: 1, WorkshopID: 1}
+	otherDir := filepath.Join(filepath.Dir(dir), "1")
+
+	err = bsputil.ExtractAssetsAtomic(f, other, otherDir)
+
+	var mismatch *bsputil.CRCMismatchError
+	assert.True(t, errors.As(err, &mismatch))
+	assert.NoDirExists(t, otherDir)
+
+	_, ok := bsputil.CachedCRCMismatch(otherDir, other)
+	assert.False(t, ok)
+
+	assert.NoError(t, bsputil.CacheCRCMismatch(otherDir, mismatch))
+
+	cached, ok := bsputil.CachedCRCMismatch(otherDir, other)
+	assert.True(t, ok)
+	assert.Equal(t, mismatch, cached)
+
+	// the CRC table entry changed
+	_, ok = bsputil.CachedCRCMismatch(otherDir, crc.Entry{MapName: "de_nuke", MapCrc32: 1, WorkshopID: 2})
+	assert.False(t, ok)
+}
@@ -14,6 +14,7 @@ import (
 	"github.com/galaco/bsp"
 	"github.com/stretchr/testify/assert"
 
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
 	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
 	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
 )
@@ -36,6 +37,21 @@ type crcTable struct {
 	} `json:"maps"`
 }
 
+// nukeBSP returns a minimal map with a radar overview.
+func nukeBSP() []byte {
+	return fixture.MapBSP(map[string][]byte{
+		"resource/overviews/de_nuke.txt":       []byte(nukeRadarInfo),
+		"resource/overviews/de_nuke_radar.dds": fixture.DDS(),
+	})
+}
+
+// steamAPI starts a stand-in for the Steam API serving nukeBSP as workshop item.
+func steamAPI(workshopID int) *fixture.SteamAPI {
+	return fixture.NewSteamAPI(map[int][]byte{
+		workshopID: fixture.Zip(map[string][]byte{"de_nuke.bsp": nukeBSP()}),
+	})
+}
+
 func TestDownload(t *testing.T) {
 	const crcTablePath = "../../test/data/crc_table.json"
 
@@ -52,7 +68,12 @@ func TestDownload(t *testing.T) {
 	assert.NoErrorf(t, err, "failed to create target file for download %q", fDownload.Name())
 	defer f.Close()
 
-	err = steamapi.DownloadWorkshopItem(tab.Maps[0].WorkshopID, fDownload)
+	api := steamAPI(tab.Maps[0].WorkshopID)
+	defer api.Close()
+
+	client := &steamapi.Client{BaseURL: api.URL}
+
+	err = client.DownloadWorkshopItem(tab.Maps[0].WorkshopID, fDownload)
 	assert.NoErrorf(t, err, "failed to download workshop item %q", tab.Maps[0].WorkshopID)
 }
 
@@ -71,7 +92,13 @@ func TestE2E(t *testing.T) {
 	var buf bytes.Buffer
 
 	workshopID := tab.Maps[0].WorkshopID
-	err = steamapi.DownloadWorkshopItem(workshopID, &buf)
+
+	api := steamAPI(workshopID)
+	defer api.Close()
+
+	client := &steamapi.Client{BaseURL: api.URL}
+
+	err = client.DownloadWorkshopItem(workshopID, &buf)
 	assert.NoErrorf(t, err, "failed to download workshop item %q", workshopID)
 
 	b := buf.Bytes()
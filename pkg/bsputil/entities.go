@@ -0,0 +1,269 @@
+package bsputil
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/galaco/bsp"
+	"github.com/pkg/errors"
+)
+
+// Vector is a position or angles (pitch, yaw, roll) in world space.
+type Vector struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+	Z float64 `json:"z"`
+}
+
+// Entity is an entity of the entity lump as key/value map, e.g. {"classname": "info_player_terrorist", "origin": "0 0 64"}.
+// If an entity contains a key multiple times (e.g. entity I/O outputs), the first value is kept.
+type Entity map[string]string
+
+// Get returns the value of key, falling back to a case-insensitive match since the engine doesn't care about key case.
+func (e Entity) Get(key string) (string, bool) {
+	v, ok := e[key]
+	if ok {
+		return v, true
+	}
+
+	for k, v := range e {
+		if strings.EqualFold(k, key) {
+			return v, true
+		}
+	}
+
+	return "", false
+}
+
+// ClassName returns the classname of the entity, e.g. "func_buyzone".
+func (e Entity) ClassName() string {
+	v, _ := e.Get("classname")
+
+	return v
+}
+
+// Vector parses a value like "-1024 512 64", missing keys result in the zero vector.
+func (e Entity) Vector(key string) (Vector, error) {
+	v, ok := e.Get(key)
+	if !ok {
+		return Vector{}, nil
+	}
+
+	fields := strings.Fields(v)
+	if len(fields) != 3 {
+		return Vector{}, errors.Errorf("failed to parse value %q of key %q as vector", v, key)
+	}
+
+	var res [3]float64
+
+	for i, f := range fields {
+		var err error
+
+		res[i], err = strconv.ParseFloat(f, 64)
+		if err != nil {
+			return Vector{}, errors.Wrapf(err, "failed to parse value %q of key %q as vector", v, key)
+		}
+	}
+
+	return Vector{X: res[0], Y: res[1], Z: res[2]}, nil
+}
+
+// Float parses a numeric value, missing keys result in def.
+func (e Entity) Float(key string, def float64) (float64, error) {
+	v, ok := e.Get(key)
+	if !ok || strings.TrimSpace(v) == "" {
+		return def, nil
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(v), 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse value %q of key %q as number", v, key)
+	}
+
+	return f, nil
+}
+
+// Int parses an integer value, missing keys result in def.
+func (e Entity) Int(key string, def int) (int, error) {
+	f, err := e.Float(key, float64(def))
+
+	return int(f), err
+}
+
+// Entities are all entities of a map, in the order of the entity lump.
+type Entities []Entity
+
+// ByClass returns all entities with the given classname.
+func (es Entities) ByClass(className string) Entities {
+	var res Entities
+
+	for _, e := range es {
+		if e.ClassName() == className {
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// GetEntities parses the entity lump of a BSP file.
+func GetEntities(f *bsp.Bsp) (Entities, error) {
+	b := f.RawLump(bsp.LumpEntities).RawContents()
+
+	// the lump is NUL terminated
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+
+	return ParseEntities(string(b))
+}
+
+// ParseEntities parses the text of an entity lump:
+//
+//	{
+//	"classname" "worldspawn"
+//	...
+//	}
+//	{
+//	...
+//	}
+func ParseEntities(s string) (Entities, error) {
+	p := &entityParser{s: s, line: 1}
+
+	var res Entities
+
+	for {
+		tok, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return res, nil
+		}
+
+		if tok != "{" {
+			return nil, p.errorf("unexpected %q, expected '{'", tok)
+		}
+
+		e, err := p.parseEntity()
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, e)
+	}
+}
+
+type entityParser struct {
+	s    string
+	pos  int
+	line int
+	// quoted is set if the last token was a quoted string, so a quoted "{" isn't mistaken for a brace
+	quoted bool
+}
+
+func (p *entityParser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("entities: line %d: "+format, append([]interface{}{p.line}, args...)...)
+}
+
+func (p *entityParser) parseEntity() (Entity, error) {
+	e := make(Entity)
+
+	for {
+		key, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok {
+			return nil, p.errorf("unexpected end of input, missing '}'")
+		}
+
+		if !p.quoted && key == "}" {
+			return e, nil
+		}
+
+		if !p.quoted && key == "{" {
+			return nil, p.errorf("unexpected '{', expected key")
+		}
+
+		val, ok, err := p.next()
+		if err != nil {
+			return nil, err
+		}
+
+		if !ok || (!p.quoted && (val == "{" || val == "}")) {
+			return nil, p.errorf("missing value for key %q", key)
+		}
+
+		if _, exists := e[key]; !exists {
+			e[key] = val
+		}
+	}
+}
+
+// next returns the next token: a brace, a quoted string (without quotes) or an unquoted word.
+func (p *entityParser) next() (string, bool, error) {
+	p.skipSpaceAndComments()
+
+	if p.pos >= len(p.s) {
+		return "", false, nil
+	}
+
+	p.quoted = false
+
+	switch c := p.s[p.pos]; c {
+	case '{', '}':
+		p.pos++
+
+		return string(c), true, nil
+
+	case '"':
+		end := strings.IndexByte(p.s[p.pos+1:], '"')
+		if end < 0 {
+			return "", false, p.errorf("unterminated string")
+		}
+
+		tok := p.s[p.pos+1 : p.pos+1+end]
+		p.line += strings.Count(tok, "\n")
+		p.pos += end + 2
+		p.quoted = true
+
+		return tok, true, nil
+	}
+
+	start := p.pos
+
+	for p.pos < len(p.s) && !isEntitySpace(p.s[p.pos]) && p.s[p.pos] != '{' && p.s[p.pos] != '}' && p.s[p.pos] != '"' {
+		p.pos++
+	}
+
+	return p.s[start:p.pos], true, nil
+}
+
+func (p *entityParser) skipSpaceAndComments() {
+	for p.pos < len(p.s) {
+		switch {
+		case p.s[p.pos] == '\n':
+			p.line++
+			p.pos++
+		case isEntitySpace(p.s[p.pos]):
+			p.pos++
+		case strings.HasPrefix(p.s[p.pos:], "//"):
+			end := strings.IndexByte(p.s[p.pos:], '\n')
+			if end < 0 {
+				p.pos = len(p.s)
+			} else {
+				p.pos += end
+			}
+		default:
+			return
+		}
+	}
+}
+
+func isEntitySpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\r' || c == '\n'
+}
@@ -0,0 +1,135 @@
+package bsputil_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/galaco/bsp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+)
+
+const entityLump = `{
+"world_maxs" "2048 2048 512"
+"classname" "worldspawn"
+"message" "{ not a brace }"
+}
+{
+"origin" "-672 -800 -400"
+"angles" "0 90 0"
+"priority" "1"
+"classname" "info_player_terrorist"
+}
+{
+"origin" "-640 -800 -400"
+"enabled" "0"
+"classname" "info_player_terrorist"
+}
+{
+"origin" "1200 -700 -410.5"
+"angles" "0 180 0"
+"classname" "info_player_counterterrorist"
+}
+{
+"model" "*12"
+"targetname" "bombsite_a"
+"classname" "func_bomb_target"
+}
+{
+"model" "*13"
+"TeamNum" "3"
+"classname" "func_buyzone"
+"OnStartTouch" "first"
+"OnStartTouch" "second"
+}
+{
+"model" "*14"
+"classname" "func_hostage_rescue"
+}
+{
+"origin" "10 20 30"
+"HostageType" "1"
+"classname" "hostage_entity"
+}
+{
+"bombradius" "350"
+"classname" "info_map_parameters"
+}
+`
+
+func TestParseEntities(t *testing.T) {
+	es, err := bsputil.ParseEntities(entityLump)
+	assert.NoError(t, err)
+	assert.Len(t, es, 9)
+
+	assert.Equal(t, "worldspawn", es[0].ClassName())
+	assert.Equal(t, "{ not a brace }", es[0]["message"])
+	assert.Equal(t, "first", es[5]["OnStartTouch"], "the first value of repeated keys should be kept")
+
+	teamNum, ok := es[5].Get("teamnum")
+	assert.True(t, ok)
+	assert.Equal(t, "3", teamNum)
+
+	assert.Len(t, es.ByClass(bsputil.ClassTerroristSpawn), 2)
+}
+
+func TestParseEntitiesErrors(t *testing.T) {
+	for _, s := range []string{
+		`{ "classname" "worldspawn"`,
+		`{ "classname" }`,
+		`"classname" "worldspawn"`,
+		`{ "classname" "worldspawn }`,
+		`{ { } }`,
+	} {
+		_, err := bsputil.ParseEntities(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestGameplay(t *testing.T) {
+	es, err := bsputil.ParseEntities(entityLump)
+	assert.NoError(t, err)
+
+	g, err := es.Gameplay()
+	assert.NoError(t, err)
+
+	assert.Equal(t, &bsputil.Gameplay{
+		TerroristSpawns: []bsputil.SpawnPoint{
+			{Origin: bsputil.Vector{X: -672, Y: -800, Z: -400}, Angles: bsputil.Vector{Y: 90}, Priority: 1, Enabled: true},
+			{Origin: bsputil.Vector{X: -640, Y: -800, Z: -400}},
+		},
+		CTSpawns: []bsputil.SpawnPoint{
+			{Origin: bsputil.Vector{X: 1200, Y: -700, Z: -410.5}, Angles: bsputil.Vector{Y: 180}, Enabled: true},
+		},
+		BombTargets:        []bsputil.BrushEntity{{Model: "*12", TargetName: "bombsite_a"}},
+		BuyZones:           []bsputil.BuyZone{{BrushEntity: bsputil.BrushEntity{Model: "*13"}, TeamNum: 3}},
+		HostageRescueZones: []bsputil.BrushEntity{{Model: "*14"}},
+		Hostages:           []bsputil.Hostage{{Origin: bsputil.Vector{X: 10, Y: 20, Z: 30}, HostageType: 1}},
+		MapParameters:      &bsputil.MapParameters{BombRadius: 350},
+	}, g)
+}
+
+func TestGameplayInvalidValue(t *testing.T) {
+	es, err := bsputil.ParseEntities(`{ "classname" "info_player_counterterrorist" "origin" "1 2" }`)
+	assert.NoError(t, err)
+
+	_, err = es.Gameplay()
+	assert.EqualError(t, err, `failed to parse CT spawn: failed to parse value "1 2" of key "origin" as vector`)
+}
+
+func TestGetEntities(t *testing.T) {
+	f, err := bsp.ReadFromStream(bytes.NewReader(fixture.BSP(map[bsp.LumpId][]byte{
+		bsp.LumpEntities: append([]byte(entityLump), 0),
+	})))
+	assert.NoError(t, err)
+
+	es, err := bsputil.GetEntities(f)
+	assert.NoError(t, err)
+	assert.Len(t, es, 9)
+
+	params, err := es.MapParameters()
+	assert.NoError(t, err)
+	assert.Equal(t, 350.0, params.BombRadius)
+}
@@ -0,0 +1,347 @@
+package bsputil
+
+import (
+	"github.com/galaco/bsp"
+	"github.com/pkg/errors"
+)
+
+// Class names of the gameplay entities.
+const (
+	ClassTerroristSpawn = "info_player_terrorist"
+	ClassCTSpawn        = "info_player_counterterrorist"
+	ClassBombTarget     = "func_bomb_target"
+	ClassBuyZone        = "func_buyzone"
+	ClassHostageRescue  = "func_hostage_rescue"
+	ClassHostage        = "hostage_entity"
+	ClassMapParameters  = "info_map_parameters"
+)
+
+// SpawnPoint is an info_player_terrorist or info_player_counterterrorist entity.
+type SpawnPoint struct {
+	Origin Vector `json:"origin"`
+	Angles Vector `json:"angles"`
+	// Priority is used by the game to pick spawns, lower values are used first.
+	Priority int  `json:"priority"`
+	Enabled  bool `json:"enabled"`
+}
+
+// BrushEntity is an entity whose volume is a brush model of the map, e.g. a func_bomb_target.
+type BrushEntity struct {
+	// Model references the brush model of the entity volume, e.g. "*3".
+	Model      string `json:"model"`
+	Origin     Vector `json:"origin"`
+	TargetName string `json:"target_name,omitempty"`
+	// Geometry is the resolved brush model, see Gameplay.ResolveGeometry.
+	Geometry *BrushModelGeometry `json:"geometry,omitempty"`
+	// GeometryError is set instead of Geometry if the brush model can't be resolved, e.g. if the model key is missing.
+	GeometryError string `json:"geometry_error,omitempty"`
+}
+
+// BuyZone is a func_buyzone entity.
+type BuyZone struct {
+	BrushEntity
+	// TeamNum is the team that can buy in the zone, 2 for T, 3 for CT and 0 for both.
+	TeamNum int `json:"team_num"`
+}
+
+// Hostage is a hostage_entity.
+type Hostage struct {
+	Origin      Vector `json:"origin"`
+	Angles      Vector `json:"angles"`
+	HostageType int    `json:"hostage_type"`
+}
+
+// MapParameters is the info_map_parameters entity.
+type MapParameters struct {
+	// BombRadius is the radius of the C4 explosion, the game uses 500 if the map doesn't set it.
+	BombRadius    float64 `json:"bomb_radius"`
+	PetPopulation int     `json:"pet_population"`
+}
+
+// Gameplay are the typed gameplay entities of a map.
+type Gameplay struct {
+	TerroristSpawns    []SpawnPoint   `json:"t_spawns"`
+	CTSpawns           []SpawnPoint   `json:"ct_spawns"`
+	BombTargets        []BrushEntity  `json:"bomb_targets"`
+	BuyZones           []BuyZone      `json:"buy_zones"`
+	HostageRescueZones []BrushEntity  `json:"hostage_rescue_zones"`
+	Hostages           []Hostage      `json:"hostages"`
+	MapParameters      *MapParameters `json:"map_parameters,omitempty"`
+}
+
+func spawnPoints(es Entities) ([]SpawnPoint, error) {
+	res := make([]SpawnPoint, 0, len(es))
+
+	for _, e := range es {
+		var (
+			sp  SpawnPoint
+			err error
+		)
+
+		sp.Origin, err = e.Vector("origin")
+		if err != nil {
+			return nil, err
+		}
+
+		sp.Angles, err = e.Vector("angles")
+		if err != nil {
+			return nil, err
+		}
+
+		sp.Priority, err = e.Int("priority", 0)
+		if err != nil {
+			return nil, err
+		}
+
+		enabled, err := e.Int("enabled", 1)
+		if err != nil {
+			return nil, err
+		}
+
+		sp.Enabled = enabled != 0
+
+		res = append(res, sp)
+	}
+
+	return res, nil
+}
+
+func brushEntity(e Entity) (BrushEntity, error) {
+	origin, err := e.Vector("origin")
+	if err != nil {
+		return BrushEntity{}, err
+	}
+
+	model, _ := e.Get("model")
+	targetName, _ := e.Get("targetname")
+
+	return BrushEntity{
+		Model:      model,
+		Origin:     origin,
+		TargetName: targetName,
+	}, nil
+}
+
+func brushEntities(es Entities) ([]BrushEntity, error) {
+	res := make([]BrushEntity, 0, len(es))
+
+	for _, e := range es {
+		be, err := brushEntity(e)
+		if err != nil {
+			return nil, err
+		}
+
+		res = append(res, be)
+	}
+
+	return res, nil
+}
+
+// TerroristSpawns returns all info_player_terrorist entities.
+func (es Entities) TerroristSpawns() ([]SpawnPoint, error) {
+	res, err := spawnPoints(es.ByClass(ClassTerroristSpawn))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse T spawn")
+	}
+
+	return res, nil
+}
+
+// CTSpawns returns all info_player_counterterrorist entities.
+func (es Entities) CTSpawns() ([]SpawnPoint, error) {
+	res, err := spawnPoints(es.ByClass(ClassCTSpawn))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse CT spawn")
+	}
+
+	return res, nil
+}
+
+// BombTargets returns all func_bomb_target entities (bombsites).
+func (es Entities) BombTargets() ([]BrushEntity, error) {
+	res, err := brushEntities(es.ByClass(ClassBombTarget))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse bomb target")
+	}
+
+	return res, nil
+}
+
+// HostageRescueZones returns all func_hostage_rescue entities.
+func (es Entities) HostageRescueZones() ([]BrushEntity, error) {
+	res, err := brushEntities(es.ByClass(ClassHostageRescue))
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse hostage rescue zone")
+	}
+
+	return res, nil
+}
+
+// BuyZones returns all func_buyzone entities.
+func (es Entities) BuyZones() ([]BuyZone, error) {
+	buyZones := es.ByClass(ClassBuyZone)
+	res := make([]BuyZone, 0, len(buyZones))
+
+	for _, e := range buyZones {
+		be, err := brushEntity(e)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse buy zone")
+		}
+
+		team, err := e.Int("TeamNum", 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse buy zone")
+		}
+
+		res = append(res, BuyZone{BrushEntity: be, TeamNum: team})
+	}
+
+	return res, nil
+}
+
+// Hostages returns all hostage_entity entities.
+func (es Entities) Hostages() ([]Hostage, error) {
+	hostages := es.ByClass(ClassHostage)
+	res := make([]Hostage, 0, len(hostages))
+
+	for _, e := range hostages {
+		var (
+			h   Hostage
+			err error
+		)
+
+		h.Origin, err = e.Vector("origin")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse hostage")
+		}
+
+		h.Angles, err = e.Vector("angles")
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse hostage")
+		}
+
+		h.HostageType, err = e.Int("HostageType", 0)
+		if err != nil {
+			return nil, errors.Wrap(err, "failed to parse hostage")
+		}
+
+		res = append(res, h)
+	}
+
+	return res, nil
+}
+
+// MapParameters returns the info_map_parameters entity, or nil if the map doesn't have one.
+func (es Entities) MapParameters() (*MapParameters, error) {
+	params := es.ByClass(ClassMapParameters)
+	if len(params) == 0 {
+		return nil, nil
+	}
+
+	bombRadius, err := params[0].Float("bombradius", 500)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse map parameters")
+	}
+
+	petPopulation, err := params[0].Int("petpopulation", 0)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse map parameters")
+	}
+
+	return &MapParameters{
+		BombRadius:    bombRadius,
+		PetPopulation: petPopulation,
+	}, nil
+}
+
+// Gameplay returns all typed gameplay entities.
+func (es Entities) Gameplay() (*Gameplay, error) {
+	var (
+		res Gameplay
+		err error
+	)
+
+	res.TerroristSpawns, err = es.TerroristSpawns()
+	if err != nil {
+		return nil, err
+	}
+
+	res.CTSpawns, err = es.CTSpawns()
+	if err != nil {
+		return nil, err
+	}
+
+	res.BombTargets, err = es.BombTargets()
+	if err != nil {
+		return nil, err
+	}
+
+	res.BuyZones, err = es.BuyZones()
+	if err != nil {
+		return nil, err
+	}
+
+	res.HostageRescueZones, err = es.HostageRescueZones()
+	if err != nil {
+		return nil, err
+	}
+
+	res.Hostages, err = es.Hostages()
+	if err != nil {
+		return nil, err
+	}
+
+	res.MapParameters, err = es.MapParameters()
+	if err != nil {
+		return nil, err
+	}
+
+	return &res, nil
+}
+
+// ResolveGeometry resolves the brush models of the bomb targets, buy zones and hostage rescue zones to world space.
+// Entities whose model can't be resolved keep a nil Geometry and get a GeometryError, the others are resolved anyway.
+func (g *Gameplay) ResolveGeometry(models *BrushModels) {
+	resolve := func(be *BrushEntity) {
+		var err error
+
+		be.Geometry, err = models.Geometry(*be)
+		if err != nil {
+			be.GeometryError = errors.Wrapf(err, "failed to resolve geometry of brush entity %q", be.Model).Error()
+		}
+	}
+
+	for i := range g.BombTargets {
+		resolve(&g.BombTargets[i])
+	}
+
+	for i := range g.BuyZones {
+		resolve(&g.BuyZones[i].BrushEntity)
+	}
+
+	for i := range g.HostageRescueZones {
+		resolve(&g.HostageRescueZones[i])
+	}
+}
+
+// GetGameplay parses the gameplay entities of a BSP file, including the geometry of the brush entities.
+func GetGameplay(f *bsp.Bsp) (*Gameplay, error) {
+	es, err := GetEntities(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse entity lump")
+	}
+
+	g, err := es.Gameplay()
+	if err != nil {
+		return nil, err
+	}
+
+	models, err := LoadBrushModels(f)
+	if err != nil {
+		return nil, err
+	}
+
+	g.ResolveGeometry(models)
+
+	return g, nil
+}
@@ -0,0 +1,333 @@
+package bsputil
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/galaco/bsp"
+	"github.com/galaco/bsp/lumps"
+	"github.com/galaco/bsp/primitives/brush"
+	"github.com/galaco/bsp/primitives/brushside"
+	"github.com/galaco/bsp/primitives/leaf"
+	"github.com/galaco/bsp/primitives/model"
+	"github.com/galaco/bsp/primitives/node"
+	"github.com/galaco/bsp/primitives/plane"
+	"github.com/pkg/errors"
+)
+
+// Vector2 is a position on the XY plane in world space, e.g. a point of a top-down outline.
+type Vector2 struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// AABB is an axis-aligned bounding box in world space.
+type AABB struct {
+	Min Vector `json:"min"`
+	Max Vector `json:"max"`
+}
+
+// BrushModelGeometry is the world space geometry of a brush model (e.g. "*3") placed by a brush entity.
+type BrushModelGeometry struct {
+	Bounds AABB `json:"bounds"`
+	// Hull is the convex hull of the model's brushes projected onto the XY plane, counter-clockwise.
+	// It's empty if the brushes can't be resolved, e.g. for maps without brush data.
+	Hull []Vector2 `json:"hull"`
+}
+
+// planeEpsilon is the tolerance for points lying on brush planes, map coordinates are usually integers.
+const planeEpsilon = 0.01
+
+// BrushModels resolves the brush models referenced by brush entities, see LoadBrushModels.
+type BrushModels struct {
+	models     []model.Model
+	planes     []plane.Plane
+	brushes    []brush.Brush
+	brushSides []brushside.BrushSide
+	nodes      []node.Node
+	leafs      []leaf.Leaf
+	leafBrush  []uint16
+}
+
+// LoadBrushModels reads the lumps needed to resolve brush models: models, BSP tree (nodes, leafs) and brushes.
+func LoadBrushModels(f *bsp.Bsp) (*BrushModels, error) {
+	var res BrushModels
+
+	lumpErr := func(name string) error {
+		return errors.Errorf("failed to read %s lump", name)
+	}
+
+	models, ok := f.Lump(bsp.LumpModels).(*lumps.Model)
+	if !ok {
+		return nil, lumpErr("models")
+	}
+
+	planes, ok := f.Lump(bsp.LumpPlanes).(*lumps.Planes)
+	if !ok {
+		return nil, lumpErr("planes")
+	}
+
+	brushes, ok := f.Lump(bsp.LumpBrushes).(*lumps.Brush)
+	if !ok {
+		return nil, lumpErr("brushes")
+	}
+
+	brushSides, ok := f.Lump(bsp.LumpBrushSides).(*lumps.BrushSide)
+	if !ok {
+		return nil, lumpErr("brush sides")
+	}
+
+	nodes, ok := f.Lump(bsp.LumpNodes).(*lumps.Node)
+	if !ok {
+		return nil, lumpErr("nodes")
+	}
+
+	leafs, ok := f.Lump(bsp.LumpLeafs).(*lumps.Leaf)
+	if !ok {
+		return nil, lumpErr("leafs")
+	}
+
+	leafBrushes, ok := f.Lump(bsp.LumpLeafBrushes).(*lumps.LeafBrush)
+	if !ok {
+		return nil, lumpErr("leaf brushes")
+	}
+
+	res.models = models.GetData()
+	res.planes = planes.GetData()
+	res.brushes = brushes.GetData()
+	res.brushSides = brushSides.GetData()
+	res.nodes = nodes.GetData()
+	res.leafs = leafs.GetData()
+	res.leafBrush = leafBrushes.GetData()
+
+	return &res, nil
+}
+
+// ParseModelIndex parses a brush model reference like "*3".
+func ParseModelIndex(ref string) (int, error) {
+	if !strings.HasPrefix(ref, "*") {
+		return 0, errors.Errorf("%q is not a brush model reference", ref)
+	}
+
+	i, err := strconv.Atoi(ref[1:])
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse brush model reference %q", ref)
+	}
+
+	return i, nil
+}
+
+// Geometry resolves the model of a brush entity to world space.
+// Brush models are stored relative to the entity origin, rotation (angles) is not applied since triggers don't use it.
+func (bm *BrushModels) Geometry(e BrushEntity) (*BrushModelGeometry, error) {
+	i, err := ParseModelIndex(e.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	if i < 0 || i >= len(bm.models) {
+		return nil, errors.Errorf("brush model %q not found, map has %d models", e.Model, len(bm.models))
+	}
+
+	m := bm.models[i]
+	origin := e.Origin
+
+	res := &BrushModelGeometry{
+		Bounds: AABB{
+			Min: Vector{X: float64(m.Mins.X()) + origin.X, Y: float64(m.Mins.Y()) + origin.Y, Z: float64(m.Mins.Z()) + origin.Z},
+			Max: Vector{X: float64(m.Maxs.X()) + origin.X, Y: float64(m.Maxs.Y()) + origin.Y, Z: float64(m.Maxs.Z()) + origin.Z},
+		},
+		Hull: []Vector2{},
+	}
+
+	var points []Vector2
+
+	for _, b := range bm.modelBrushes(m.HeadNode) {
+		for _, v := range bm.brushVertices(b) {
+			points = append(points, Vector2{X: v.X + origin.X, Y: v.Y + origin.Y})
+		}
+	}
+
+	if len(points) > 0 {
+		res.Hull = convexHull(points)
+	}
+
+	return res, nil
+}
+
+// modelBrushes returns the indices of all brushes in the leafs of the BSP subtree of a model.
+func (bm *BrushModels) modelBrushes(headNode int32) []int {
+	var (
+		res   []int
+		seen  = make(map[int]bool)
+		stack = []int32{headNode}
+	)
+
+	for len(stack) > 0 {
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		// negative children reference leafs as -(leaf + 1)
+		if n < 0 {
+			l := int(-1 - n)
+			if l >= len(bm.leafs) {
+				continue
+			}
+
+			lf := bm.leafs[l]
+
+			for j := int(lf.FirstLeafBrush); j < int(lf.FirstLeafBrush)+int(lf.NumLeafBrushes) && j < len(bm.leafBrush); j++ {
+				b := int(bm.leafBrush[j])
+				if !seen[b] {
+					seen[b] = true
+					res = append(res, b)
+				}
+			}
+
+			continue
+		}
+
+		if int(n) >= len(bm.nodes) {
+			continue
+		}
+
+		stack = append(stack, bm.nodes[n].Children[0], bm.nodes[n].Children[1])
+	}
+
+	sort.Ints(res)
+
+	return res
+}
+
+// brushVertices returns the corners of a brush, which is the intersection of the half-spaces behind its side planes.
+func (bm *BrushModels) brushVertices(b int) []Vector {
+	if b >= len(bm.brushes) {
+		return nil
+	}
+
+	var planes []plane.Plane
+
+	br := bm.brushes[b]
+
+	for i := br.FirstSide; i < br.FirstSide+br.NumSides && int(i) < len(bm.brushSides); i++ {
+		side := bm.brushSides[i]
+
+		// bevel planes are only added for collision and don't change the shape,
+		// the low byte is the bevel flag and the high byte the thin flag (galaco/bsp reads both as one int16)
+		if side.Bevel&0xff != 0 || int(side.PlaneNum) >= len(bm.planes) {
+			continue
+		}
+
+		planes = append(planes, bm.planes[side.PlaneNum])
+	}
+
+	var res []Vector
+
+	for i := 0; i < len(planes); i++ {
+		for j := i + 1; j < len(planes); j++ {
+			for k := j + 1; k < len(planes); k++ {
+				v, ok := intersectPlanes(planes[i], planes[j], planes[k])
+				if ok && insidePlanes(v, planes) {
+					res = append(res, v)
+				}
+			}
+		}
+	}
+
+	return res
+}
+
+func vec(p plane.Plane) (x, y, z float64) {
+	return float64(p.Normal.X()), float64(p.Normal.Y()), float64(p.Normal.Z())
+}
+
+func cross(ax, ay, az, bx, by, bz float64) (x, y, z float64) {
+	return ay*bz - az*by, az*bx - ax*bz, ax*by - ay*bx
+}
+
+// intersectPlanes returns the point where three planes meet, if they aren't parallel.
+func intersectPlanes(p1, p2, p3 plane.Plane) (Vector, bool) {
+	x1, y1, z1 := vec(p1)
+	x2, y2, z2 := vec(p2)
+	x3, y3, z3 := vec(p3)
+
+	c23x, c23y, c23z := cross(x2, y2, z2, x3, y3, z3)
+
+	det := x1*c23x + y1*c23y + z1*c23z
+	if math.Abs(det) < 1e-9 {
+		return Vector{}, false
+	}
+
+	c31x, c31y, c31z := cross(x3, y3, z3, x1, y1, z1)
+	c12x, c12y, c12z := cross(x1, y1, z1, x2, y2, z2)
+
+	d1, d2, d3 := float64(p1.Distance), float64(p2.Distance), float64(p3.Distance)
+
+	return Vector{
+		X: (d1*c23x + d2*c31x + d3*c12x) / det,
+		Y: (d1*c23y + d2*c31y + d3*c12y) / det,
+		Z: (d1*c23z + d2*c31z + d3*c12z) / det,
+	}, true
+}
+
+func insidePlanes(v Vector, planes []plane.Plane) bool {
+	for _, p := range planes {
+		x, y, z := vec(p)
+		if x*v.X+y*v.Y+z*v.Z > float64(p.Distance)+planeEpsilon {
+			return false
+		}
+	}
+
+	return true
+}
+
+// convexHull returns the convex hull of points in counter-clockwise order (Andrew's monotone chain), without collinear points.
+func convexHull(points []Vector2) []Vector2 {
+	// round away float noise from the plane intersections so duplicates and collinear points are detected
+	for i := range points {
+		points[i] = Vector2{X: math.Round(points[i].X*1000) / 1000, Y: math.Round(points[i].Y*1000) / 1000}
+	}
+
+	sort.Slice(points, func(i, j int) bool {
+		if points[i].X != points[j].X {
+			return points[i].X < points[j].X
+		}
+
+		return points[i].Y < points[j].Y
+	})
+
+	turn := func(o, a, b Vector2) float64 {
+		return (a.X-o.X)*(b.Y-o.Y) - (a.Y-o.Y)*(b.X-o.X)
+	}
+
+	hull := make([]Vector2, 0, 2*len(points))
+
+	// lower hull, then upper hull
+	for pass := 0; pass < 2; pass++ {
+		start := len(hull)
+
+		for i := range points {
+			p := points[i]
+			if pass == 1 {
+				p = points[len(points)-1-i]
+			}
+
+			for len(hull) >= start+2 && turn(hull[len(hull)-2], hull[len(hull)-1], p) <= 0 {
+				hull = hull[:len(hull)-1]
+			}
+
+			hull = append(hull, p)
+		}
+
+		// the last point is the first point of the other half
+		hull = hull[:len(hull)-1]
+	}
+
+	if len(hull) == 0 {
+		return points[:1]
+	}
+
+	return hull
+}
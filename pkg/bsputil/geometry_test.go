@@ -0,0 +1,255 @@
+package bsputil_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"testing"
+
+	"github.com/galaco/bsp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+)
+
+type testPlane struct {
+	Normal [3]float32
+	Dist   float32
+	Type   int32
+}
+
+// testBrushSide is a side of a brush, bevel sides are collision-only planes.
+type testBrushSide struct {
+	plane testPlane
+	bevel bool
+}
+
+type testBrush []testBrushSide
+
+func box(min, max [3]float32) testBrush {
+	var b testBrush
+
+	for axis := 0; axis < 3; axis++ {
+		var pos, neg testPlane
+
+		pos.Normal[axis], pos.Dist = 1, max[axis]
+		neg.Normal[axis], neg.Dist = -1, -min[axis]
+
+		b = append(b, testBrushSide{plane: pos}, testBrushSide{plane: neg})
+	}
+
+	return b
+}
+
+func (b testBrush) withPlane(p testPlane, bevel bool) testBrush {
+	return append(append(testBrush(nil), b...), testBrushSide{plane: p, bevel: bevel})
+}
+
+func write(buf *bytes.Buffer, v interface{}) {
+	err := binary.Write(buf, binary.LittleEndian, v)
+	if err != nil {
+		panic(err)
+	}
+}
+
+// brushModelBSP builds a BSP file with the given entity lump and brush models (model i is "*i").
+// Each model has a node with one leaf containing its brushes and an empty leaf.
+func brushModelBSP(entities string, models [][]testBrush) []byte {
+	var planes, brushes, sides, nodes, leafs, leafBrushes, modelLump bytes.Buffer
+
+	var nPlanes, nSides, nBrushes, nLeafBrushes int
+
+	for i, m := range models {
+		firstLeafBrush := nLeafBrushes
+		mins := [3]float32{math.MaxFloat32, math.MaxFloat32, math.MaxFloat32}
+		maxs := [3]float32{-math.MaxFloat32, -math.MaxFloat32, -math.MaxFloat32}
+
+		for _, b := range m {
+			write(&brushes, []int32{int32(nSides), int32(len(b)), 1})
+
+			for _, side := range b {
+				write(&planes, side.plane)
+
+				var bevel int16
+				if side.bevel {
+					bevel = 1
+				}
+
+				write(&sides, []int16{int16(nPlanes), 0, -1, bevel})
+				nPlanes++
+				nSides++
+
+				// the bounds of axial planes
+				for axis := 0; axis < 3; axis++ {
+					switch side.plane.Normal[axis] {
+					case 1:
+						if side.plane.Dist > maxs[axis] {
+							maxs[axis] = side.plane.Dist
+						}
+					case -1:
+						if -side.plane.Dist < mins[axis] {
+							mins[axis] = -side.plane.Dist
+						}
+					}
+				}
+			}
+
+			write(&leafBrushes, uint16(nBrushes))
+			nBrushes++
+			nLeafBrushes++
+		}
+
+		// leaf 2i holds the brushes, leaf 2i+1 is empty
+		for j, n := range []int{len(m), 0} {
+			write(&leafs, struct {
+				Contents        int32
+				Cluster, Flags  int16
+				Mins, Maxs      [3]int16
+				FirstLeafFace   uint16
+				NumLeafFaces    uint16
+				FirstLeafBrush  uint16
+				NumLeafBrushes  uint16
+				LeafWaterDataID int16
+				_               [2]byte
+			}{Cluster: -1, FirstLeafBrush: uint16(firstLeafBrush + j*len(m)), NumLeafBrushes: uint16(n), LeafWaterDataID: -1})
+		}
+
+		write(&nodes, struct {
+			PlaneNum   int32
+			Children   [2]int32
+			Mins, Maxs [3]int16
+			FirstFace  uint16
+			NumFaces   uint16
+			Area       int16
+			Padding    int16
+		}{Children: [2]int32{int32(-1 - 2*i), int32(-2 - 2*i)}})
+
+		if len(m) == 0 {
+			mins, maxs = [3]float32{}, [3]float32{}
+		}
+
+		write(&modelLump, struct {
+			Mins, Maxs, Origin  [3]float32
+			HeadNode            int32
+			FirstFace, NumFaces int32
+		}{Mins: mins, Maxs: maxs, HeadNode: int32(i)})
+	}
+
+	return fixture.BSP(map[bsp.LumpId][]byte{
+		bsp.LumpEntities:    append([]byte(entities), 0),
+		bsp.LumpPlanes:      planes.Bytes(),
+		bsp.LumpNodes:       nodes.Bytes(),
+		bsp.LumpLeafs:       leafs.Bytes(),
+		bsp.LumpModels:      modelLump.Bytes(),
+		bsp.LumpLeafBrushes: leafBrushes.Bytes(),
+		bsp.LumpBrushes:     brushes.Bytes(),
+		bsp.LumpBrushSides:  sides.Bytes(),
+		bsp.LumpPakfile:     fixture.Zip(nil),
+	})
+}
+
+const geometryEntities = `{
+"classname" "worldspawn"
+}
+{
+"model" "*1"
+"targetname" "bombsite_a"
+"classname" "func_bomb_target"
+}
+{
+"model" "*2"
+"origin" "1000 0 0"
+"TeamNum" "2"
+"classname" "func_buyzone"
+}
+`
+
+func TestGetGameplayGeometry(t *testing.T) {
+	sqrt2 := float32(math.Sqrt2)
+
+	b := brushModelBSP(geometryEntities, [][]testBrush{
+		// world
+		{},
+		// an L-shaped bombsite made of two boxes
+		{
+			box([3]float32{0, 0, 0}, [3]float32{200, 100, 64}),
+			box([3]float32{0, 100, 0}, [3]float32{100, 300, 64}),
+		},
+		// a triangular buy zone (x >= 0, y >= 0, x + y <= 100) with a bevel plane that would cut it if it wasn't ignored
+		{
+			box([3]float32{0, 0, 0}, [3]float32{100, 100, 128}).
+				withPlane(testPlane{Normal: [3]float32{1 / sqrt2, 1 / sqrt2, 0}, Dist: 100 / sqrt2}, false).
+				withPlane(testPlane{Normal: [3]float32{1, 0, 0}, Dist: 10}, true),
+		},
+	})
+
+	f, err := bsp.ReadFromStream(bytes.NewReader(b))
+	assert.NoError(t, err)
+
+	g, err := bsputil.GetGameplay(f)
+	assert.NoError(t, err)
+
+	if assert.Len(t, g.BombTargets, 1) {
+		assert.Equal(t, &bsputil.BrushModelGeometry{
+			Bounds: bsputil.AABB{
+				Min: bsputil.Vector{X: 0, Y: 0, Z: 0},
+				Max: bsputil.Vector{X: 200, Y: 300, Z: 64},
+			},
+			Hull: []bsputil.Vector2{{X: 0, Y: 0}, {X: 200, Y: 0}, {X: 200, Y: 100}, {X: 100, Y: 300}, {X: 0, Y: 300}},
+		}, g.BombTargets[0].Geometry)
+	}
+
+	if assert.Len(t, g.BuyZones, 1) {
+		geom := g.BuyZones[0].Geometry
+		assert.Equal(t, bsputil.AABB{
+			Min: bsputil.Vector{X: 1000, Y: 0, Z: 0},
+			Max: bsputil.Vector{X: 1100, Y: 100, Z: 128},
+		}, geom.Bounds, "the bounds should be moved by the entity origin")
+		assert.Equal(t, []bsputil.Vector2{{X: 1000, Y: 0}, {X: 1100, Y: 0}, {X: 1000, Y: 100}}, geom.Hull)
+	}
+}
+
+func TestBrushModelsGeometryErrors(t *testing.T) {
+	f, err := bsp.ReadFromStream(bytes.NewReader(brushModelBSP(geometryEntities, [][]testBrush{{}})))
+	assert.NoError(t, err)
+
+	models, err := bsputil.LoadBrushModels(f)
+	assert.NoError(t, err)
+
+	_, err = models.Geometry(bsputil.BrushEntity{Model: "*1"})
+	assert.EqualError(t, err, `brush model "*1" not found, map has 1 models`)
+
+	_, err = models.Geometry(bsputil.BrushEntity{Model: "models/props/crate.mdl"})
+	assert.EqualError(t, err, `"models/props/crate.mdl" is not a brush model reference`)
+
+	g, err := bsputil.GetGameplay(f)
+	assert.NoError(t, err, "unresolvable brush models shouldn't fail the whole map")
+
+	if assert.Len(t, g.BombTargets, 1) {
+		assert.Nil(t, g.BombTargets[0].Geometry)
+		assert.Equal(t, `failed to resolve geometry of brush entity "*1": brush model "*1" not found, map has 1 models`, g.BombTargets[0].GeometryError)
+	}
+}
+
+func TestResolveGeometryKeepsGoing(t *testing.T) {
+	f, err := bsp.ReadFromStream(bytes.NewReader(brushModelBSP(geometryEntities, [][]testBrush{
+		{},
+		{box([3]float32{0, 0, 0}, [3]float32{10, 10, 10})},
+	})))
+	assert.NoError(t, err)
+
+	models, err := bsputil.LoadBrushModels(f)
+	assert.NoError(t, err)
+
+	g := &bsputil.Gameplay{
+		BombTargets: []bsputil.BrushEntity{{Model: ""}, {Model: "*1"}},
+	}
+
+	g.ResolveGeometry(models)
+
+	assert.Nil(t, g.BombTargets[0].Geometry)
+	assert.Equal(t, `failed to resolve geometry of brush entity "": "" is not a brush model reference`, g.BombTargets[0].GeometryError)
+	assert.NotNil(t, g.BombTargets[1].Geometry)
+	assert.Empty(t, g.BombTargets[1].GeometryError)
+}
@@ -0,0 +1,135 @@
+package bsputil
+
+import (
+	"archive/zip"
+	"regexp"
+
+	"github.com/galaco/bsp"
+	"github.com/pkg/errors"
+)
+
+// lumpNames are the names of the lumps of version 21 (CS:GO) BSP files, as in the engine's bspfile.h without the LUMP_ prefix.
+var lumpNames = [64]string{
+	"entities", "planes", "texdata", "vertexes", "visibility", "nodes", "texinfo", "faces",
+	"lighting", "occlusion", "leafs", "faceids", "edges", "surfedges", "models", "worldlights",
+	"leaffaces", "leafbrushes", "brushes", "brushsides", "areas", "areaportals", "propcollision", "prophulls",
+	"prophullverts", "proptris", "dispinfo", "originalfaces", "physdisp", "physcollide", "vertnormals", "vertnormalindices",
+	"disp_lightmap_alphas", "disp_verts", "disp_lightmap_sample_positions", "game_lump", "leafwaterdata", "primitives", "primverts", "primindices",
+	"pakfile", "clipportalverts", "cubemaps", "texdata_string_data", "texdata_string_table", "overlays", "leafmindisttowater", "face_macro_texture_info",
+	"disp_tris", "prop_blob", "wateroverlays", "leaf_ambient_index_hdr", "leaf_ambient_index", "lighting_hdr", "worldlights_hdr", "leaf_ambient_lighting_hdr",
+	"leaf_ambient_lighting", "xzippakfile", "faces_hdr", "map_flags", "overlay_fades", "overlay_system_levels", "physlevel", "disp_multiblend",
+}
+
+// LumpInfo describes a lump of a BSP file.
+type LumpInfo struct {
+	ID      int    `json:"id"`
+	Name    string `json:"name"`
+	Version int32  `json:"version"`
+	Size    int32  `json:"size"`
+}
+
+// PakfileInfo summarizes the pakfile (embedded zip) of a BSP file.
+type PakfileInfo struct {
+	Files int `json:"files"`
+	// Size is the total uncompressed size of all files.
+	Size uint64 `json:"size"`
+}
+
+// Info is the metadata of a BSP file, see GetInfo.
+type Info struct {
+	Version  int32 `json:"version"`
+	Revision int32 `json:"revision"`
+	// CRC32 is the map CRC as calculated by the engine, see VerifyBSP.
+	CRC32 uint32 `json:"crc32"`
+	// Lumps are all non-empty lumps, ordered by ID.
+	Lumps   []LumpInfo  `json:"lumps"`
+	Pakfile PakfileInfo `json:"pakfile"`
+	// MapName is detected from the radar overview info file, see GetMapName.
+	MapName string `json:"map_name,omitempty"`
+	// HasRadar, HasNav and HasOverview report whether the pakfile contains
+	// a radar image (resource/overviews/<map>_radar.dds), a nav mesh (maps/<map>.nav) and a radar overview info file (resource/overviews/<map>.txt).
+	HasRadar    bool       `json:"has_radar"`
+	HasNav      bool       `json:"has_nav"`
+	HasOverview bool       `json:"has_overview"`
+	Radar       *RadarInfo `json:"radar,omitempty"`
+	// RadarError is set instead of Radar if the radar overview info file can't be parsed.
+	RadarError string `json:"radar_error,omitempty"`
+}
+
+var radarImageFilePattern = regexp.MustCompile("^resource/overviews/[^/]+_radar\\.dds$")
+
+// GetInfo collects the metadata of a BSP file.
+// Maps without radar overview info are fine, the map name and radar info are just left empty.
+// If the radar overview info can't be parsed, the error is reported in RadarError.
+func GetInfo(f *bsp.Bsp) (*Info, error) {
+	h := f.Header()
+
+	crc, err := f.CRC32()
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to calculate map CRC")
+	}
+
+	info := &Info{
+		Version:  h.Version,
+		Revision: h.Revision,
+		CRC32:    crc,
+		Lumps:    []LumpInfo{},
+	}
+
+	for id, l := range h.Lumps {
+		if l.Length == 0 {
+			continue
+		}
+
+		info.Lumps = append(info.Lumps, LumpInfo{
+			ID:      id,
+			Name:    lumpNames[id],
+			Version: l.Version,
+			Size:    l.Length,
+		})
+	}
+
+	pakfile, err := Pakfile(f)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to read pakfile data")
+	}
+
+	info.Pakfile = pakfileInfo(pakfile)
+
+	pakF, mapName := findRadarInfoFile(pakfile)
+	if pakF != nil {
+		info.MapName = mapName
+		info.HasOverview = true
+
+		info.Radar, err = parseRadarInfoFile(pakF)
+		if err != nil {
+			info.RadarError = err.Error()
+		}
+	}
+
+	for _, pakF := range pakfile.File {
+		switch {
+		case radarImageFilePattern.MatchString(pakF.Name):
+			info.HasRadar = true
+		case navMeshFilePattern.MatchString(pakF.Name):
+			info.HasNav = true
+		}
+	}
+
+	return info, nil
+}
+
+func pakfileInfo(pakfile *zip.Reader) PakfileInfo {
+	var res PakfileInfo
+
+	for _, pakF := range pakfile.File {
+		if pakF.FileInfo().IsDir() {
+			continue
+		}
+
+		res.Files++
+		res.Size += pakF.UncompressedSize64
+	}
+
+	return res
+}
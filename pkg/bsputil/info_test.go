@@ -0,0 +1,85 @@
+package bsputil_test
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/galaco/bsp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+)
+
+func TestGetInfo(t *testing.T) {
+	b := nukeBSP()
+
+	f, err := bsp.ReadFromStream(bytes.NewReader(b))
+	assert.NoError(t, err)
+
+	info, err := bsputil.GetInfo(f)
+	assert.NoError(t, err)
+
+	assert.Equal(t, int32(21), info.Version)
+	assert.Equal(t, int32(1), info.Revision)
+	assert.Equal(t, fixture.MapCRC(b), info.CRC32)
+	assert.Equal(t, []bsputil.LumpInfo{{
+		ID:   int(bsp.LumpPakfile),
+		Name: "pakfile",
+		Size: int32(len(f.RawLump(bsp.LumpPakfile).RawContents())),
+	}}, info.Lumps)
+	assert.Equal(t, bsputil.PakfileInfo{Files: 2, Size: uint64(len(nukeRadarInfo) + len(fixture.DDS()))}, info.Pakfile)
+	assert.Equal(t, "de_nuke", info.MapName)
+	assert.True(t, info.HasRadar)
+	assert.True(t, info.HasOverview)
+	assert.False(t, info.HasNav)
+
+	if assert.NotNil(t, info.Radar) {
+		assert.Equal(t, "de_nuke", info.Radar.MapName)
+		assert.Equal(t, 7.0, info.Radar.Scale)
+	}
+}
+
+func TestGetInfoNoRadar(t *testing.T) {
+	f, err := bsp.ReadFromStream(bytes.NewReader(fixture.MapBSP(map[string][]byte{
+		"maps/de_empty.nav": []byte("nav"),
+	})))
+	assert.NoError(t, err)
+
+	info, err := bsputil.GetInfo(f)
+	assert.NoError(t, err)
+	assert.Empty(t, info.MapName)
+	assert.Nil(t, info.Radar)
+	assert.False(t, info.HasRadar)
+	assert.False(t, info.HasOverview)
+	assert.True(t, info.HasNav)
+}
+
+func TestGetInfoRadarWithoutOverview(t *testing.T) {
+	f, err := bsp.ReadFromStream(bytes.NewReader(fixture.MapBSP(map[string][]byte{
+		"resource/overviews/de_x_radar.dds": fixture.DDS(),
+	})))
+	assert.NoError(t, err)
+
+	info, err := bsputil.GetInfo(f)
+	assert.NoError(t, err)
+	assert.True(t, info.HasRadar)
+	assert.False(t, info.HasOverview)
+	assert.Nil(t, info.Radar)
+}
+
+func TestGetInfoInvalidOverview(t *testing.T) {
+	f, err := bsp.ReadFromStream(bytes.NewReader(fixture.MapBSP(map[string][]byte{
+		"resource/overviews/de_x.txt":       []byte(`"de_x" { "scale" "abc" }`),
+		"resource/overviews/de_x_radar.dds": fixture.DDS(),
+	})))
+	assert.NoError(t, err)
+
+	info, err := bsputil.GetInfo(f)
+	assert.NoError(t, err)
+	assert.Equal(t, "de_x", info.MapName)
+	assert.True(t, info.HasRadar)
+	assert.True(t, info.HasOverview)
+	assert.Nil(t, info.Radar)
+	assert.Contains(t, info.RadarError, `failed to parse radar-info .txt file: "resource/overviews/de_x.txt"`)
+}
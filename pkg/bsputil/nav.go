@@ -0,0 +1,49 @@
+package bsputil
+
+import (
+	"archive/zip"
+	"io"
+	"regexp"
+
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/nav"
+)
+
+var navMeshFilePattern = regexp.MustCompile("^maps/([^/]+)\\.nav$")
+
+var ErrNavMeshNotFound = errors.New("failed to find nav mesh in BSP file")
+
+// GetNavMeshFile returns a reader for the nav mesh file (maps/<map>.nav) in the pakfile.
+func GetNavMeshFile(pakfile *zip.Reader) (io.ReadCloser, error) {
+	for _, pakF := range pakfile.File {
+		if !navMeshFilePattern.MatchString(pakF.Name) {
+			continue
+		}
+
+		r, err := pakF.Open()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to open nav mesh file: %q", pakF.Name)
+		}
+
+		return r, nil
+	}
+
+	return nil, ErrNavMeshNotFound
+}
+
+// GetNavMesh finds and parses the nav mesh file (maps/<map>.nav) in the pakfile.
+func GetNavMesh(pakfile *zip.Reader) (*nav.Mesh, error) {
+	r, err := GetNavMeshFile(pakfile)
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+
+	mesh, err := nav.Parse(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse nav mesh")
+	}
+
+	return mesh, nil
+}
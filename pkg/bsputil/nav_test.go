@@ -0,0 +1,31 @@
+package bsputil_test
+
+import (
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+)
+
+func TestGetNavMeshFile(t *testing.T) {
+	pakfile := pakfileZip(t, map[string]string{
+		"maps/de_nuke.nav":               "nav data",
+		"resource/overviews/de_nuke.txt": nukeRadarInfo,
+	})
+
+	r, err := bsputil.GetNavMeshFile(pakfile)
+	assert.NoError(t, err)
+	defer r.Close()
+
+	b, err := io.ReadAll(r)
+	assert.NoError(t, err)
+	assert.Equal(t, "nav data", string(b))
+
+	_, err = bsputil.GetNavMesh(pakfile)
+	assert.Error(t, err, "invalid nav data should fail to parse")
+
+	_, err = bsputil.GetNavMeshFile(pakfileZip(t, nil))
+	assert.ErrorIs(t, err, bsputil.ErrNavMeshNotFound)
+}
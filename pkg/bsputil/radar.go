@@ -0,0 +1,526 @@
+package bsputil
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"image"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/dds"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/vdf"
+)
+
+// Point is a position on the radar image, normalized to [0, 1] in both dimensions.
+type Point struct {
+	X float64 `json:"x"`
+	Y float64 `json:"y"`
+}
+
+// VerticalSection is an entry of the "verticalsections" block of a radar info file.
+// Name is the section's key, e.g. "default" or "lower".
+type VerticalSection struct {
+	Name        string  `json:"name"`
+	AltitudeMin float64 `json:"altitude_min"`
+	AltitudeMax float64 `json:"altitude_max"`
+}
+
+// RadarInfo is the parsed content of a radar overview info file (resource/overviews/<map>.txt).
+type RadarInfo struct {
+	MapName          string                   `json:"map_name"`
+	Material         string                   `json:"material,omitempty"`
+	PosX             float64                  `json:"pos_x"`
+	PosY             float64                  `json:"pos_y"`
+	Scale            float64                  `json:"scale"`
+	Rotate           float64                  `json:"rotate"`
+	Zoom             float64                  `json:"zoom"`
+	VerticalSections []VerticalSection        `json:"vertical_sections,omitempty"`
+	CTSpawn          *Point                   `json:"ct_spawn,omitempty"`
+	TSpawn           *Point                   `json:"t_spawn,omitempty"`
+	BombA            *Point                   `json:"bomb_a,omitempty"`
+	BombB            *Point                   `json:"bomb_b,omitempty"`
+	Unknown          map[string]*vdf.KeyValue `json:"unknown,omitempty"` // all keys not covered by the fields above, by original key
+}
+
+// pointCoordinate returns the coordinate a key like "bombA_x" refers to, or nil if the key isn't a known point.
+func (ri *RadarInfo) pointCoordinate(key string) *float64 {
+	points := map[string]**Point{
+		"ctspawn": &ri.CTSpawn,
+		"tspawn":  &ri.TSpawn,
+		"bomba":   &ri.BombA,
+		"bombb":   &ri.BombB,
+	}
+
+	i := strings.LastIndexByte(key, '_')
+	if i < 0 {
+		return nil
+	}
+
+	p, ok := points[key[:i]]
+	if !ok {
+		return nil
+	}
+
+	axis := key[i+1:]
+	if axis != "x" && axis != "y" {
+		return nil
+	}
+
+	if *p == nil {
+		*p = new(Point)
+	}
+
+	if axis == "x" {
+		return &(*p).X
+	}
+
+	return &(*p).Y
+}
+
+func parseFloat(kv *vdf.KeyValue) (float64, error) {
+	if kv.IsSection() {
+		return 0, errors.Errorf("expected value for key %q, got section", kv.Key)
+	}
+
+	f, err := strconv.ParseFloat(strings.TrimSpace(kv.Value), 64)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to parse value %q of key %q as number", kv.Value, kv.Key)
+	}
+
+	return f, nil
+}
+
+func parseVerticalSections(kv *vdf.KeyValue) ([]VerticalSection, error) {
+	if !kv.IsSection() {
+		return nil, errors.Errorf("expected section for key %q, got value", kv.Key)
+	}
+
+	sections := make([]VerticalSection, 0, len(kv.Children))
+
+	for _, c := range kv.Children {
+		if !c.IsSection() {
+			continue
+		}
+
+		section := VerticalSection{Name: c.Key}
+
+		for _, attr := range c.Children {
+			var dst *float64
+
+			switch strings.ToLower(attr.Key) {
+			case "altitudemin":
+				dst = &section.AltitudeMin
+			case "altitudemax":
+				dst = &section.AltitudeMax
+			default:
+				continue
+			}
+
+			f, err := parseFloat(attr)
+			if err != nil {
+				return nil, errors.Wrapf(err, "failed to parse vertical section %q", c.Key)
+			}
+
+			*dst = f
+		}
+
+		sections = append(sections, section)
+	}
+
+	return sections, nil
+}
+
+// ParseRadarInfo parses a radar overview info file (resource/overviews/<map>.txt).
+func ParseRadarInfo(r io.Reader) (*RadarInfo, error) {
+	root, err := vdf.Parse(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse radar info as KeyValues")
+	}
+
+	if len(root.Children) == 0 || !root.Children[0].IsSection() {
+		return nil, errors.New("radar info does not contain a top-level section")
+	}
+
+	top := root.Children[0]
+
+	info := &RadarInfo{
+		MapName: top.Key,
+		Unknown: make(map[string]*vdf.KeyValue),
+	}
+
+	for _, kv := range top.Children {
+		key := strings.ToLower(kv.Key)
+
+		var (
+			dst *float64
+			err error
+		)
+
+		switch key {
+		case "material":
+			info.Material = kv.Value
+			continue
+		case "pos_x":
+			dst = &info.PosX
+		case "pos_y":
+			dst = &info.PosY
+		case "scale":
+			dst = &info.Scale
+		case "rotate":
+			dst = &info.Rotate
+		case "zoom":
+			dst = &info.Zoom
+		case "verticalsections":
+			info.VerticalSections, err = parseVerticalSections(kv)
+			if err != nil {
+				return nil, err
+			}
+
+			continue
+		}
+
+		if dst == nil {
+			dst = info.pointCoordinate(key)
+		}
+
+		if dst == nil {
+			info.Unknown[kv.Key] = kv
+			continue
+		}
+
+		*dst, err = parseFloat(kv)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if len(info.Unknown) == 0 {
+		info.Unknown = nil
+	}
+
+	return info, nil
+}
+
+func findRadarInfoFile(pakfile *zip.Reader) (*zip.File, string) {
+	for _, pakF := range pakfile.File {
+		matches := radarOverviewInfoFilePattern.FindStringSubmatch(pakF.Name)
+
+		if len(matches) > 0 {
+			return pakF, matches[1]
+		}
+	}
+
+	return nil, ""
+}
+
+func parseRadarInfoFile(pakF *zip.File) (*RadarInfo, error) {
+	r, err := pakF.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open radar-info .txt file: %q", pakF.Name)
+	}
+	defer r.Close()
+
+	info, err := ParseRadarInfo(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to parse radar-info .txt file: %q", pakF.Name)
+	}
+
+	return info, nil
+}
+
+// GetRadarInfo finds and parses the radar overview info file (resource/overviews/<map>.txt) in the pakfile.
+func GetRadarInfo(pakfile *zip.Reader) (*RadarInfo, error) {
+	pakF, _ := findRadarInfoFile(pakfile)
+	if pakF == nil {
+		return nil, ErrRadarImageNotFound
+	}
+
+	return parseRadarInfoFile(pakF)
+}
+
+// GetRadar is like GetRadarImage but returns the parsed radar info instead of the raw .txt file.
+func GetRadar(pakfile *zip.Reader) (*RadarInfo, io.ReadCloser, error) {
+	pakF, mapName := findRadarInfoFile(pakfile)
+	if pakF == nil {
+		return nil, nil, ErrRadarImageNotFound
+	}
+
+	info, err := parseRadarInfoFile(pakF)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	ddsPath := fmt.Sprintf("resource/overviews/%s_radar.dds", mapName)
+
+	fOverview, err := pakfile.Open(ddsPath)
+	if err != nil {
+		return nil, nil, errors.Wrapf(err, "failed to open radar-overview .dds file: %q", ddsPath)
+	}
+
+	return info, fOverview, nil
+}
+
+// RadarLayer is a radar image of one level of a map, e.g. resource/overviews/de_nuke_lower_radar.dds.
+type RadarLayer struct {
+	// Name is the vertical section of the layer, DefaultVerticalSection for the primary radar image.
+	Name string `json:"name"`
+	// Path is the path of the .dds file in the pakfile.
+	Path string `json:"path"`
+	// Section is the altitude range of the layer, nil if the radar info has no vertical section for it.
+	Section *VerticalSection `json:"section,omitempty"`
+}
+
+// GetRadarLayers returns the parsed radar info and the in-game radar image of every level of a map,
+// i.e. the radar assets without variant (see GetRadarAssets), in the same order.
+// Unlike GetRadarAssets it fails if the radar info can't be parsed.
+func GetRadarLayers(pakfile *zip.Reader) (*RadarInfo, []RadarLayer, error) {
+	info, err := GetRadarInfo(pakfile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	assets, err := GetRadarAssets(pakfile)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var layers []RadarLayer
+
+	for _, a := range assets.Assets {
+		if a.Variant != "" {
+			continue
+		}
+
+		layers = append(layers, RadarLayer{Name: a.Level, Path: a.Path, Section: a.Section})
+	}
+
+	if len(layers) == 0 {
+		return nil, nil, errors.Wrapf(ErrRadarImageNotFound, "no radar images for map %q", assets.MapName)
+	}
+
+	return info, layers, nil
+}
+
+// DecodeRadarImage decodes a radar overview .dds file.
+// Radar images are stored bottom-up, so the result is flipped to be displayed as-is.
+func DecodeRadarImage(r io.Reader) (image.Image, error) {
+	img, err := dds.Decode(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode radar image as DDS")
+	}
+
+	return dds.FlipVertical(img), nil
+}
+
+// DecodeRadarMipMaps decodes all surfaces of a radar overview .dds file, largest first, flipped like DecodeRadarImage.
+func DecodeRadarMipMaps(r io.Reader) ([]image.Image, error) {
+	imgs, err := dds.DecodeAll(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode radar image as DDS")
+	}
+
+	for i, img := range imgs {
+		imgs[i] = dds.FlipVertical(img)
+	}
+
+	return imgs, nil
+}
+
+// RadarVariantSpectate is the variant of radar images used by the spectator overview, e.g. de_train_radar_spectate.dds.
+const RadarVariantSpectate = "spectate"
+
+// RadarAsset is a radar image of a map, classified by its file name: resource/overviews/<map>[_<level>]_radar[_<variant>].dds
+type RadarAsset struct {
+	// Path is the path of the .dds file in the pakfile.
+	Path string `json:"path"`
+	// Level is the vertical section the image shows, DefaultVerticalSection for the primary level.
+	Level string `json:"level"`
+	// Variant is empty for the in-game radar, RadarVariantSpectate for the spectator overview or the file name suffix of other variants.
+	Variant string `json:"variant,omitempty"`
+	// Section is the altitude range of the level, nil if the radar info has no vertical section for it.
+	Section *VerticalSection `json:"section,omitempty"`
+	Width   int              `json:"width"`
+	Height  int              `json:"height"`
+	// MipMaps is the number of surfaces in the .dds file, including the main surface.
+	MipMaps int `json:"mipmaps"`
+	// HeaderError is set if the header of the .dds file can't be read or its pixel format isn't supported by package dds,
+	// the header information above is incomplete then but the file can still be extracted as-is.
+	HeaderError string `json:"header_error,omitempty"`
+}
+
+// RadarAssets are all radar images of a map with the parsed radar info, see GetRadarAssets.
+type RadarAssets struct {
+	MapName string `json:"map_name"`
+	// Info is nil if the radar info file can't be parsed, see InfoError.
+	Info      *RadarInfo   `json:"info"`
+	InfoError string       `json:"info_error,omitempty"`
+	Assets    []RadarAsset `json:"assets"`
+}
+
+// Get returns the radar image of a level and variant, e.g. Get(DefaultVerticalSection, RadarVariantSpectate).
+func (ra *RadarAssets) Get(level, variant string) (*RadarAsset, bool) {
+	for i, a := range ra.Assets {
+		if strings.EqualFold(a.Level, level) && strings.EqualFold(a.Variant, variant) {
+			return &ra.Assets[i], true
+		}
+	}
+
+	return nil, false
+}
+
+// ClassifyRadarFile returns the level and variant of a radar image path of a map, see RadarAsset.
+// ok is false if the path isn't a radar image of the map.
+func ClassifyRadarFile(mapName, path string) (level, variant string, ok bool) {
+	prefix := strings.ToLower("resource/overviews/" + mapName)
+	path = strings.ToLower(path)
+
+	if !strings.HasPrefix(path, prefix) || !strings.HasSuffix(path, ".dds") {
+		return "", "", false
+	}
+
+	name := strings.TrimSuffix(strings.TrimPrefix(path, prefix), ".dds")
+	if strings.Contains(name, "/") {
+		return "", "", false
+	}
+
+	i := strings.LastIndex(name, "_radar")
+	if i < 0 {
+		return "", "", false
+	}
+
+	level, variant = name[:i], name[i+len("_radar"):]
+
+	switch {
+	case level == "":
+		level = DefaultVerticalSection
+	case strings.HasPrefix(level, "_") && len(level) > 1:
+		level = level[1:]
+	default:
+		return "", "", false
+	}
+
+	switch {
+	case variant == "":
+	case strings.HasPrefix(variant, "_") && len(variant) > 1:
+		variant = variant[1:]
+	default:
+		return "", "", false
+	}
+
+	return level, variant, true
+}
+
+// radarAsset reads the header information of a radar image, as much as it can.
+func radarAsset(pakF *zip.File) (RadarAsset, error) {
+	asset := RadarAsset{Path: pakF.Name}
+
+	r, err := pakF.Open()
+	if err != nil {
+		return asset, errors.Wrapf(err, "failed to open radar image %q", pakF.Name)
+	}
+	defer r.Close()
+
+	// the header is small, buffer it to read it twice
+	var header bytes.Buffer
+
+	asset.MipMaps, err = dds.MipMapCount(io.TeeReader(r, &header))
+	if err != nil {
+		return asset, errors.Wrapf(err, "failed to decode header of radar image %q", pakF.Name)
+	}
+
+	cfg, err := dds.DecodeConfig(&header)
+	if err != nil {
+		return asset, errors.Wrapf(err, "failed to decode header of radar image %q", pakF.Name)
+	}
+
+	asset.Width, asset.Height = cfg.Width, cfg.Height
+
+	return asset, nil
+}
+
+// GetRadarAssets finds all radar images of a map in the pakfile (see RadarAsset) and parses the radar info.
+// Radar images are classified by file name only, their headers and the radar info are read best-effort:
+// errors are reported in RadarAsset.HeaderError and InfoError instead of failing or skipping images.
+// Assets are ordered by level (the default level first, then as in the vertical sections of the radar info) and variant.
+func GetRadarAssets(pakfile *zip.Reader) (*RadarAssets, error) {
+	pakF, mapName := findRadarInfoFile(pakfile)
+	if pakF == nil {
+		return nil, ErrRadarImageNotFound
+	}
+
+	res := &RadarAssets{
+		MapName: mapName,
+		Assets:  []RadarAsset{},
+	}
+
+	info, err := parseRadarInfoFile(pakF)
+	if err != nil {
+		res.InfoError = err.Error()
+		// without vertical sections
+		info = &RadarInfo{MapName: mapName}
+	} else {
+		res.Info = info
+	}
+
+	for _, f := range pakfile.File {
+		level, variant, ok := ClassifyRadarFile(mapName, f.Name)
+		if !ok {
+			continue
+		}
+
+		asset, err := radarAsset(f)
+		if err != nil {
+			asset.HeaderError = err.Error()
+		}
+
+		asset.Level, asset.Variant = level, variant
+
+		for i := range info.VerticalSections {
+			if strings.EqualFold(info.VerticalSections[i].Name, level) {
+				asset.Section = &info.VerticalSections[i]
+				break
+			}
+		}
+
+		res.Assets = append(res.Assets, asset)
+	}
+
+	if len(res.Assets) == 0 {
+		return nil, errors.Wrapf(ErrRadarImageNotFound, "no radar images for map %q", mapName)
+	}
+
+	levelRank := func(a RadarAsset) int {
+		if a.Level == DefaultVerticalSection {
+			return 0
+		}
+
+		for i, s := range info.VerticalSections {
+			if strings.EqualFold(s.Name, a.Level) {
+				return i + 1
+			}
+		}
+
+		return len(info.VerticalSections) + 1
+	}
+
+	sort.SliceStable(res.Assets, func(i, j int) bool {
+		a, b := res.Assets[i], res.Assets[j]
+
+		if ra, rb := levelRank(a), levelRank(b); ra != rb {
+			return ra < rb
+		}
+
+		if a.Level != b.Level {
+			return a.Level < b.Level
+		}
+
+		return a.Variant < b.Variant
+	})
+
+	return res, nil
+}
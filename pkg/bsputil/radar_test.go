@@ -0,0 +1,290 @@
+package bsputil_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+)
+
+const nukeRadarInfo = `"de_nuke"
+{
+	"material"	"overviews/de_nuke"
+	"pos_x"		"-3453"
+	"pos_y"		"2887"
+	"scale"		"7"
+	"rotate"	"0"
+	"zoom"		"0"
+	"verticalsections"
+	{
+		"default" // use the primary radar image
+		{
+			"AltitudeMax" "10000"
+			"AltitudeMin" "-495"
+		}
+		"lower" // i.e. de_nuke_lower_radar.dds
+		{
+			"AltitudeMax" "-495"
+			"AltitudeMin" "-10000"
+		}
+	}
+	"CTSpawn_x"	"0.82"
+	"CTSpawn_y"	"0.45"
+	"TSpawn_x"	"0.19"
+	"TSpawn_y"	"0.54"
+	"bombA_x"	"0.58"
+	"bombA_y"	"0.48"
+	"bombB_x"	"0.58"
+	"bombB_y"	"0.58"
+	"inset_left"	"0.33"
+}
+`
+
+func pakfileZip(t *testing.T, files map[string]string) *zip.Reader {
+	t.Helper()
+
+	var buf bytes.Buffer
+
+	w := zip.NewWriter(&buf)
+
+	for name, content := range files {
+		f, err := w.Create(name)
+		assert.NoError(t, err)
+
+		_, err = io.WriteString(f, content)
+		assert.NoError(t, err)
+	}
+
+	assert.NoError(t, w.Close())
+
+	r, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	assert.NoError(t, err)
+
+	return r
+}
+
+func TestParseRadarInfo(t *testing.T) {
+	info, err := bsputil.ParseRadarInfo(strings.NewReader(nukeRadarInfo))
+	assert.NoError(t, err)
+
+	assert.Equal(t, "de_nuke", info.MapName)
+	assert.Equal(t, "overviews/de_nuke", info.Material)
+	assert.Equal(t, -3453.0, info.PosX)
+	assert.Equal(t, 2887.0, info.PosY)
+	assert.Equal(t, 7.0, info.Scale)
+	assert.Equal(t, []bsputil.VerticalSection{
+		{Name: "default", AltitudeMin: -495, AltitudeMax: 10000},
+		{Name: "lower", AltitudeMin: -10000, AltitudeMax: -495},
+	}, info.VerticalSections)
+	assert.Equal(t, &bsputil.Point{X: 0.82, Y: 0.45}, info.CTSpawn)
+	assert.Equal(t, &bsputil.Point{X: 0.19, Y: 0.54}, info.TSpawn)
+	assert.Equal(t, &bsputil.Point{X: 0.58, Y: 0.48}, info.BombA)
+	assert.Equal(t, &bsputil.Point{X: 0.58, Y: 0.58}, info.BombB)
+
+	assert.Len(t, info.Unknown, 1)
+	assert.Equal(t, "0.33", info.Unknown["inset_left"].Value)
+}
+
+func TestParseRadarInfoInvalidNumber(t *testing.T) {
+	_, err := bsputil.ParseRadarInfo(strings.NewReader(`"de_x" { "scale" "abc" }`))
+	assert.Error(t, err)
+}
+
+func TestGetRadar(t *testing.T) {
+	pakfile := pakfileZip(t, map[string]string{
+		"resource/overviews/de_nuke.txt":       nukeRadarInfo,
+		"resource/overviews/de_nuke_radar.dds": "DDS ",
+	})
+
+	info, img, err := bsputil.GetRadar(pakfile)
+	assert.NoError(t, err)
+	defer img.Close()
+
+	assert.Equal(t, "de_nuke", info.MapName)
+
+	b, err := io.ReadAll(img)
+	assert.NoError(t, err)
+	assert.Equal(t, "DDS ", string(b))
+
+	_, _, err = bsputil.GetRadar(pakfileZip(t, nil))
+	assert.ErrorIs(t, err, bsputil.ErrRadarImageNotFound)
+}
+
+func TestGetRadarLayers(t *testing.T) {
+	dds := string(fixture.DDS())
+
+	pakfile := pakfileZip(t, map[string]string{
+		"resource/overviews/de_nuke.txt":                nukeRadarInfo,
+		"resource/overviews/de_nuke_radar.dds":          dds,
+		"resource/overviews/de_nuke_lower_radar.dds":    dds,
+		"resource/overviews/de_nuke_upper_radar.dds":    dds,
+		"resource/overviews/de_nuke_radar_spectate.dds": dds,
+	})
+
+	info, layers, err := bsputil.GetRadarLayers(pakfile)
+	assert.NoError(t, err)
+	assert.Equal(t, "de_nuke", info.MapName)
+
+	assert.Equal(t, []bsputil.RadarLayer{
+		{
+			Name:    "default",
+			Path:    "resource/overviews/de_nuke_radar.dds",
+			Section: &bsputil.VerticalSection{Name: "default", AltitudeMin: -495, AltitudeMax: 10000},
+		},
+		{
+			Name:    "lower",
+			Path:    "resource/overviews/de_nuke_lower_radar.dds",
+			Section: &bsputil.VerticalSection{Name: "lower", AltitudeMin: -10000, AltitudeMax: -495},
+		},
+		{
+			Name: "upper",
+			Path: "resource/overviews/de_nuke_upper_radar.dds",
+		},
+	}, layers)
+}
+
+func TestGetRadarLayersWithoutSections(t *testing.T) {
+	dds := string(fixture.DDS())
+
+	pakfile := pakfileZip(t, map[string]string{
+		"resource/overviews/de_x.txt":       `"de_x" { "scale" "5" "verticalsections" { "higher" { "AltitudeMin" "100" "AltitudeMax" "1000" } } }`,
+		"resource/overviews/de_x_radar.dds": dds,
+	})
+
+	_, layers, err := bsputil.GetRadarLayers(pakfile)
+	assert.NoError(t, err)
+	assert.Equal(t, []bsputil.RadarLayer{{Name: "default", Path: "resource/overviews/de_x_radar.dds"}}, layers,
+		"sections without radar image should be skipped")
+
+	_, _, err = bsputil.GetRadarLayers(pakfileZip(t, map[string]string{
+		"resource/overviews/de_x.txt": `"de_x" { "scale" "5" }`,
+	}))
+	assert.ErrorIs(t, err, bsputil.ErrRadarImageNotFound)
+}
+
+func TestClassifyRadarFile(t *testing.T) {
+	for _, tc := range []struct {
+		path    string
+		level   string
+		variant string
+		ok      bool
+	}{
+		{"resource/overviews/de_train_radar.dds", "default", "", true},
+		{"resource/overviews/de_train_radar_spectate.dds", "default", "spectate", true},
+		{"resource/overviews/de_train_lower_radar.dds", "lower", "", true},
+		{"resource/overviews/de_train_lower_radar_spectate.dds", "lower", "spectate", true},
+		{"resource/overviews/DE_TRAIN_radar_alt.dds", "default", "alt", true},
+		{"resource/overviews/de_train.txt", "", "", false},
+		{"resource/overviews/de_train_icon.dds", "", "", false},
+		{"resource/overviews/de_trainyard_radar.dds", "", "", false},
+		{"resource/overviews/de_train_radar_.dds", "", "", false},
+		{"materials/overviews/de_train_radar.dds", "", "", false},
+	} {
+		level, variant, ok := bsputil.ClassifyRadarFile("de_train", tc.path)
+		assert.Equal(t, tc.ok, ok, tc.path)
+		assert.Equal(t, tc.level, level, tc.path)
+		assert.Equal(t, tc.variant, variant, tc.path)
+	}
+}
+
+func TestGetRadarAssets(t *testing.T) {
+	dds := string(fixture.DDS())
+
+	pakfile := pakfileZip(t, map[string]string{
+		"resource/overviews/de_nuke.txt":                      nukeRadarInfo,
+		"resource/overviews/de_nuke_radar_spectate.dds":       dds,
+		"resource/overviews/de_nuke_upper_radar.dds":          dds,
+		"resource/overviews/de_nuke_lower_radar_spectate.dds": dds,
+		"resource/overviews/de_nuke_lower_radar.dds":          dds,
+		"resource/overviews/de_nuke_radar.dds":                dds,
+		"materials/overviews/de_nuke_radar.dds":               dds,
+	})
+
+	assets, err := bsputil.GetRadarAssets(pakfile)
+	assert.NoError(t, err)
+	assert.Equal(t, "de_nuke", assets.MapName)
+	assert.Equal(t, "de_nuke", assets.Info.MapName)
+
+	defaultSection := &bsputil.VerticalSection{Name: "default", AltitudeMin: -495, AltitudeMax: 10000}
+	lowerSection := &bsputil.VerticalSection{Name: "lower", AltitudeMin: -10000, AltitudeMax: -495}
+
+	asset := func(path, level, variant string, section *bsputil.VerticalSection) bsputil.RadarAsset {
+		return bsputil.RadarAsset{Path: path, Level: level, Variant: variant, Section: section, Width: 4, Height: 4, MipMaps: 1}
+	}
+
+	assert.Equal(t, []bsputil.RadarAsset{
+		asset("resource/overviews/de_nuke_radar.dds", "default", "", defaultSection),
+		asset("resource/overviews/de_nuke_radar_spectate.dds", "default", "spectate", defaultSection),
+		asset("resource/overviews/de_nuke_lower_radar.dds", "lower", "", lowerSection),
+		asset("resource/overviews/de_nuke_lower_radar_spectate.dds", "lower", "spectate", lowerSection),
+		asset("resource/overviews/de_nuke_upper_radar.dds", "upper", "", nil),
+	}, assets.Assets)
+
+	spectate, ok := assets.Get(bsputil.DefaultVerticalSection, bsputil.RadarVariantSpectate)
+	assert.True(t, ok)
+	assert.Equal(t, "resource/overviews/de_nuke_radar_spectate.dds", spectate.Path)
+
+	_, ok = assets.Get("upper", bsputil.RadarVariantSpectate)
+	assert.False(t, ok)
+}
+
+func TestGetRadarAssetsErrors(t *testing.T) {
+	_, err := bsputil.GetRadarAssets(pakfileZip(t, map[string]string{
+		"resource/overviews/de_nuke.txt": nukeRadarInfo,
+	}))
+	assert.ErrorIs(t, err, bsputil.ErrRadarImageNotFound)
+
+	_, err = bsputil.GetRadarAssets(pakfileZip(t, nil))
+	assert.ErrorIs(t, err, bsputil.ErrRadarImageNotFound)
+}
+
+func TestGetRadarAssetsUnreadableHeaders(t *testing.T) {
+	dx10 := bytes.Replace(fixture.DDS(), []byte("DXT1"), []byte("DX10"), 1)
+
+	assets, err := bsputil.GetRadarAssets(pakfileZip(t, map[string]string{
+		"resource/overviews/de_nuke.txt":             nukeRadarInfo,
+		"resource/overviews/de_nuke_radar.dds":       string(dx10),
+		"resource/overviews/de_nuke_lower_radar.dds": "DDS ",
+	}))
+	assert.NoError(t, err, "radar images are classified by file name only")
+
+	assert.Equal(t, []bsputil.RadarAsset{
+		{
+			Path:        "resource/overviews/de_nuke_radar.dds",
+			Level:       "default",
+			Section:     &bsputil.VerticalSection{Name: "default", AltitudeMin: -495, AltitudeMax: 10000},
+			MipMaps:     1,
+			HeaderError: `failed to decode header of radar image "resource/overviews/de_nuke_radar.dds": FourCC "DX10": dds: unsupported pixel format`,
+		},
+		{
+			Path:        "resource/overviews/de_nuke_lower_radar.dds",
+			Level:       "lower",
+			Section:     &bsputil.VerticalSection{Name: "lower", AltitudeMin: -10000, AltitudeMax: -495},
+			HeaderError: `failed to decode header of radar image "resource/overviews/de_nuke_lower_radar.dds": dds: failed to read header: EOF`,
+		},
+	}, assets.Assets)
+}
+
+func TestGetRadarAssetsInvalidInfo(t *testing.T) {
+	pakfile := pakfileZip(t, map[string]string{
+		"resource/overviews/de_nuke.txt":       `"de_nuke" { "scale" "big" }`,
+		"resource/overviews/de_nuke_radar.dds": string(fixture.DDS()),
+	})
+
+	assets, err := bsputil.GetRadarAssets(pakfile)
+	assert.NoError(t, err)
+	assert.Nil(t, assets.Info)
+	assert.Contains(t, assets.InfoError, `failed to parse radar-info .txt file: "resource/overviews/de_nuke.txt"`)
+	assert.Equal(t, []bsputil.RadarAsset{
+		{Path: "resource/overviews/de_nuke_radar.dds", Level: "default", Width: 4, Height: 4, MipMaps: 1},
+	}, assets.Assets)
+
+	_, _, err = bsputil.GetRadarLayers(pakfile)
+	assert.Error(t, err, "layers need the radar info")
+}
@@ -0,0 +1,114 @@
+package bsputil
+
+import (
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// DefaultVerticalSection is the name of the vertical section that uses the primary radar image (<map>_radar.dds).
+const DefaultVerticalSection = "default"
+
+// radarResolution is the radar image resolution pos_x, pos_y and scale of the radar info refer to.
+const radarResolution = 1024
+
+// RadarTransform converts between world coordinates and radar image pixels, see NewRadarTransform.
+type RadarTransform struct {
+	PosX  float64
+	PosY  float64
+	Scale float64
+	// Width and Height are the resolution of the radar image in pixels.
+	Width  int
+	Height int
+	// Rotation is the counter-clockwise rotation of the radar image around its center, in degrees.
+	Rotation         float64
+	VerticalSections []VerticalSection
+}
+
+// NewRadarTransform returns the transform for a radar image with the given resolution.
+// The "rotate" key of radar info files is a flag, rotated radars are turned by 90°.
+func NewRadarTransform(info *RadarInfo, width, height int) (*RadarTransform, error) {
+	if info.Scale <= 0 {
+		return nil, errors.Errorf("invalid radar scale %v", info.Scale)
+	}
+
+	if width <= 0 || height <= 0 {
+		return nil, errors.Errorf("invalid radar image resolution %dx%d", width, height)
+	}
+
+	t := &RadarTransform{
+		PosX:             info.PosX,
+		PosY:             info.PosY,
+		Scale:            info.Scale,
+		Width:            width,
+		Height:           height,
+		VerticalSections: info.VerticalSections,
+	}
+
+	if info.Rotate != 0 {
+		t.Rotation = 90
+	}
+
+	return t, nil
+}
+
+// rotate rotates a normalized radar point counter-clockwise around the image center.
+func rotate(p Point, degrees float64) Point {
+	if degrees == 0 {
+		return p
+	}
+
+	sin, cos := math.Sincos(degrees * math.Pi / 180)
+	dx, dy := p.X-0.5, p.Y-0.5
+
+	// the y axis of images points down, so this is counter-clockwise on screen
+	return Point{
+		X: 0.5 + dx*cos + dy*sin,
+		Y: 0.5 - dx*sin + dy*cos,
+	}
+}
+
+// WorldToPoint converts a world position to a radar position normalized to [0, 1], like the points of RadarInfo.
+func (t *RadarTransform) WorldToPoint(v Vector) Point {
+	p := Point{
+		X: (v.X - t.PosX) / t.Scale / radarResolution,
+		Y: (t.PosY - v.Y) / t.Scale / radarResolution,
+	}
+
+	return rotate(p, t.Rotation)
+}
+
+// PointToWorld converts a normalized radar position to a world position at height z.
+func (t *RadarTransform) PointToWorld(p Point, z float64) Vector {
+	p = rotate(p, -t.Rotation)
+
+	return Vector{
+		X: t.PosX + p.X*radarResolution*t.Scale,
+		Y: t.PosY - p.Y*radarResolution*t.Scale,
+		Z: z,
+	}
+}
+
+// WorldToRadar converts a world position to pixel coordinates on the radar image.
+func (t *RadarTransform) WorldToRadar(v Vector) (x, y float64) {
+	p := t.WorldToPoint(v)
+
+	return p.X * float64(t.Width), p.Y * float64(t.Height)
+}
+
+// RadarToWorld converts pixel coordinates on the radar image to a world position at height z.
+func (t *RadarTransform) RadarToWorld(x, y, z float64) Vector {
+	return t.PointToWorld(Point{X: x / float64(t.Width), Y: y / float64(t.Height)}, z)
+}
+
+// Section returns the name of the vertical section (radar image) for height z.
+// Sections cover [AltitudeMin, AltitudeMax), heights outside of all sections use DefaultVerticalSection.
+func (t *RadarTransform) Section(z float64) string {
+	for _, s := range t.VerticalSections {
+		if z >= s.AltitudeMin && z < s.AltitudeMax {
+			return s.Name
+		}
+	}
+
+	return DefaultVerticalSection
+}
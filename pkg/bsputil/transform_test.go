@@ -0,0 +1,80 @@
+package bsputil_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+)
+
+func nukeTransform(t *testing.T, width, height int) *bsputil.RadarTransform {
+	t.Helper()
+
+	info, err := bsputil.ParseRadarInfo(strings.NewReader(nukeRadarInfo))
+	assert.NoError(t, err)
+
+	tr, err := bsputil.NewRadarTransform(info, width, height)
+	assert.NoError(t, err)
+
+	return tr
+}
+
+func TestRadarTransform(t *testing.T) {
+	tr := nukeTransform(t, 1024, 1024)
+
+	x, y := tr.WorldToRadar(bsputil.Vector{X: -3453, Y: 2887})
+	assert.Equal(t, 0.0, x)
+	assert.Equal(t, 0.0, y)
+
+	// scale 7 means 7 units per pixel
+	x, y = tr.WorldToRadar(bsputil.Vector{X: -3453 + 700, Y: 2887 - 1400})
+	assert.Equal(t, 100.0, x)
+	assert.Equal(t, 200.0, y)
+
+	assert.Equal(t, bsputil.Vector{X: -3453 + 700, Y: 2887 - 1400, Z: -400}, tr.RadarToWorld(100, 200, -400))
+
+	tr = nukeTransform(t, 2048, 512)
+
+	x, y = tr.WorldToRadar(bsputil.Vector{X: -3453 + 700, Y: 2887 - 1400})
+	assert.Equal(t, 200.0, x)
+	assert.Equal(t, 100.0, y)
+
+	assert.Equal(t, bsputil.Point{X: 100.0 / 1024, Y: 200.0 / 1024}, tr.WorldToPoint(bsputil.Vector{X: -3453 + 700, Y: 2887 - 1400}))
+}
+
+func TestRadarTransformRotation(t *testing.T) {
+	tr := nukeTransform(t, 1024, 1024)
+	tr.Rotation = 90
+
+	// the right edge of the unrotated radar is at the top after a counter-clockwise rotation
+	x, y := tr.WorldToRadar(bsputil.Vector{X: -3453 + 1024*7, Y: 2887 - 512*7})
+	assert.InDelta(t, 512, x, 1e-9)
+	assert.InDelta(t, 0, y, 1e-9)
+
+	v := tr.RadarToWorld(x, y, 0)
+	assert.InDelta(t, -3453+1024*7, v.X, 1e-9)
+	assert.InDelta(t, 2887-512*7, v.Y, 1e-9)
+}
+
+func TestRadarTransformSection(t *testing.T) {
+	tr := nukeTransform(t, 1024, 1024)
+
+	assert.Equal(t, "default", tr.Section(0))
+	assert.Equal(t, "default", tr.Section(-495))
+	assert.Equal(t, "lower", tr.Section(-600))
+	assert.Equal(t, bsputil.DefaultVerticalSection, tr.Section(20000))
+}
+
+func TestNewRadarTransformInvalid(t *testing.T) {
+	_, err := bsputil.NewRadarTransform(&bsputil.RadarInfo{}, 1024, 1024)
+	assert.EqualError(t, err, "invalid radar scale 0")
+
+	_, err = bsputil.NewRadarTransform(&bsputil.RadarInfo{Scale: 5, Rotate: 1}, 0, 1024)
+	assert.EqualError(t, err, "invalid radar image resolution 0x1024")
+
+	tr, err := bsputil.NewRadarTransform(&bsputil.RadarInfo{Scale: 5, Rotate: 1}, 1024, 1024)
+	assert.NoError(t, err)
+	assert.Equal(t, 90.0, tr.Rotation)
+}
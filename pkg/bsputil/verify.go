@@ -0,0 +1,39 @@
+package bsputil
+
+import (
+	"fmt"
+
+	"github.com/galaco/bsp"
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+)
+
+// CRCMismatchError is returned by VerifyBSP if the map CRC of a BSP doesn't match its CRC table entry,
+// usually because the workshop item was updated since the table was extracted.
+type CRCMismatchError struct {
+	Entry  crc.Entry
+	Actual uint32
+}
+
+func (e *CRCMismatchError) Error() string {
+	return fmt.Sprintf("BSP has map CRC %d, expected %d for %s from workshop item %d", e.Actual, e.Entry.MapCrc32, e.Entry.MapName, e.Entry.WorkshopID)
+}
+
+// VerifyBSP checks that the map CRC of f matches entry.
+// The CRC is calculated like the engine does when loading a map (all lumps except the entities), which is what demos and the CRC table contain.
+func VerifyBSP(f *bsp.Bsp, entry crc.Entry) error {
+	actual, err := f.CRC32()
+	if err != nil {
+		return errors.Wrap(err, "failed to calculate map CRC")
+	}
+
+	if actual != entry.MapCrc32 {
+		return &CRCMismatchError{
+			Entry:  entry,
+			Actual: actual,
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,36 @@
+package bsputil_test
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/galaco/bsp"
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+)
+
+func TestVerifyBSP(t *testing.T) {
+	b := nukeBSP()
+
+	f, err := bsp.ReadFromStream(bytes.NewReader(b))
+	assert.NoError(t, err)
+
+	entry := crc.Entry{MapName: "de_nuke", MapCrc32: fixture.MapCRC(b), WorkshopID: 1}
+	assert.NoError(t, bsputil.VerifyBSP(f, entry))
+
+	// the entities lump isn't part of the map CRC
+	withEntities := fixture.BSP(map[bsp.LumpId][]byte{
+		bsp.LumpEntities: []byte(`{ "classname" "worldspawn" }`),
+		bsp.LumpPakfile:  f.RawLump(bsp.LumpPakfile).RawContents(),
+	})
+	assert.Equal(t, entry.MapCrc32, fixture.MapCRC(withEntities))
+
+	entry.MapCrc32++
+	err = bsputil.VerifyBSP(f, entry)
+	assert.Equal(t, &bsputil.CRCMismatchError{Entry: entry, Actual: entry.MapCrc32 - 1}, err)
+	assert.EqualError(t, err, fmt.Sprintf("BSP has map CRC %d, expected %d for de_nuke from workshop item 1", entry.MapCrc32-1, entry.MapCrc32))
+}
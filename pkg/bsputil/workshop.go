@@ -0,0 +1,108 @@
+package bsputil
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io"
+	"path/filepath"
+	"strings"
+
+	"github.com/galaco/bsp"
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+var ErrBSPNotFound = errors.New("failed to find .bsp file in workshop item")
+
+// FindBSP returns the first .bsp file in a workshop item zip.
+func FindBSP(zipR *zip.Reader) (*zip.File, error) {
+	for _, zipF := range zipR.File {
+		if strings.EqualFold(filepath.Ext(zipF.Name), ".bsp") {
+			return zipF, nil
+		}
+	}
+
+	return nil, ErrBSPNotFound
+}
+
+// ReadWorkshopBSP reads the BSP contained in a workshop item zip.
+func ReadWorkshopBSP(zipR *zip.Reader) (*bsp.Bsp, error) {
+	zipF, err := FindBSP(zipR)
+	if err != nil {
+		return nil, err
+	}
+
+	r, err := zipF.Open()
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open BSP file in zip %q", zipF.Name)
+	}
+	defer r.Close()
+
+	bspF, err := bsp.ReadFromStream(r)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to read BSP data from zip file stream %q", zipF.Name)
+	}
+
+	return bspF, nil
+}
+
+// downloadWorkshopZip downloads a workshop item into memory.
+// Zip archives can only be read with random access (the directory is at the end), so the item has to be buffered.
+func downloadWorkshopZip(ctx context.Context, client *steamapi.Client, workshopID int) (*zip.Reader, error) {
+	var buf bytes.Buffer
+
+	err := client.DownloadWorkshopItemContext(ctx, workshopID, &buf)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to download workshop item %d", workshopID)
+	}
+
+	zipR, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to open workshop item %d as zip", workshopID)
+	}
+
+	return zipR, nil
+}
+
+// DownloadWorkshopBSP downloads a workshop item and writes the contained .bsp file to w.
+func DownloadWorkshopBSP(ctx context.Context, client *steamapi.Client, workshopID int, w io.Writer) error {
+	zipR, err := downloadWorkshopZip(ctx, client, workshopID)
+	if err != nil {
+		return err
+	}
+
+	zipF, err := FindBSP(zipR)
+	if err != nil {
+		return errors.Wrapf(err, "workshop item %d", workshopID)
+	}
+
+	r, err := zipF.Open()
+	if err != nil {
+		return errors.Wrapf(err, "failed to open BSP file in zip %q", zipF.Name)
+	}
+	defer r.Close()
+
+	_, err = io.Copy(w, r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to extract BSP file %q", zipF.Name)
+	}
+
+	return nil
+}
+
+// GetWorkshopBSP downloads a workshop item and reads the contained .bsp file.
+func GetWorkshopBSP(ctx context.Context, client *steamapi.Client, workshopID int) (*bsp.Bsp, error) {
+	zipR, err := downloadWorkshopZip(ctx, client, workshopID)
+	if err != nil {
+		return nil, err
+	}
+
+	bspF, err := ReadWorkshopBSP(zipR)
+	if err != nil {
+		return nil, errors.Wrapf(err, "workshop item %d", workshopID)
+	}
+
+	return bspF, nil
+}
@@ -0,0 +1,46 @@
+package bsputil_test
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+func TestGetWorkshopBSP(t *testing.T) {
+	const workshopID = 157233767
+
+	api := steamAPI(workshopID)
+	defer api.Close()
+
+	client := &steamapi.Client{BaseURL: api.URL}
+
+	bspF, err := bsputil.GetWorkshopBSP(context.Background(), client, workshopID)
+	assert.NoError(t, err)
+
+	pakfile, err := bsputil.Pakfile(bspF)
+	assert.NoError(t, err)
+
+	mapName, err := bsputil.GetMapName(pakfile)
+	assert.NoError(t, err)
+	assert.Equal(t, "de_nuke", mapName)
+
+	var buf bytes.Buffer
+
+	err = bsputil.DownloadWorkshopBSP(context.Background(), client, workshopID, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "VBSP", buf.String()[:4])
+}
+
+func TestGetWorkshopBSPNoBSP(t *testing.T) {
+	api := fixture.NewSteamAPI(map[int][]byte{1: fixture.Zip(map[string][]byte{"readme.txt": []byte("hi")})})
+	defer api.Close()
+
+	_, err := bsputil.GetWorkshopBSP(context.Background(), &steamapi.Client{BaseURL: api.URL}, 1)
+	assert.ErrorIs(t, err, bsputil.ErrBSPNotFound)
+}
@@ -2,191 +2,304 @@ package crc
 
 import (
 	"bytes"
+	"debug/elf"
 	"encoding/binary"
-	"encoding/hex"
 	"io"
 
 	"github.com/pkg/errors"
 )
 
-const chunkSize = 4096
-
-// see https://forum.golangbridge.org/t/how-to-find-the-offset-of-a-byte-in-a-large-binary-file/16457
-func find(r io.Reader, search []byte) (int64, error) {
-	var offset int64
-
-	tailLen := len(search) - 1
-	chunk := make([]byte, chunkSize+tailLen)
-	n, err := r.Read(chunk[tailLen:])
-	idx := bytes.Index(chunk[tailLen:n+tailLen], search)
+type Entry struct {
+	MapName    string `json:"map_name"`
+	MapCrc32   uint32 `json:"map_crc"`
+	WorkshopID uint32 `json:"workshop_id"`
+}
 
-	for {
-		if idx >= 0 {
-			return offset + int64(idx), nil
-		}
+type Reader interface {
+	io.Reader
+	io.ReaderAt
+}
 
-		if err == io.EOF {
-			return -1, nil
-		} else if err != nil {
-			return -1, err
-		}
+const (
+	// entrySize is the size of one `cmp dword [rbx+0x20], imm32; je rel32` pair.
+	entrySize = 13
+	// mapNameDispOffset is the offset of the RIP-relative displacement of the map name from the jump target.
+	mapNameDispOffset = 10
+	// workshopIDOffset is the offset of the workshop ID immediate from the jump target.
+	workshopIDOffset = 27
+
+	mapNameMaxLength = 64
+	// maxFirstCompareGap is the maximum number of bytes between the first compare/jump pair and the rest of the chain.
+	maxFirstCompareGap = 16
+)
 
-		copy(chunk, chunk[chunkSize:])
+// ErrUnknownFormat is returned by ExtractCRCTable for files that are neither ELF nor PE binaries.
+var ErrUnknownFormat = errors.New("unknown binary format, expected engine_client.so (ELF) or engine.dll (PE)")
 
-		offset += chunkSize
-		n, err = r.Read(chunk[tailLen:])
-		idx = bytes.Index(chunk[:n+tailLen], search)
-	}
-}
+// isPE checks for the MZ header and the PE signature at the offset stored in its e_lfanew field.
+func isPE(r io.ReaderAt) bool {
+	dosHeader := make([]byte, 0x40)
 
-func clen(n []byte) int {
-	for i := 0; i < len(n); i++ {
-		if n[i] == 0 {
-			return i
-		}
+	_, err := r.ReadAt(dosHeader, 0)
+	if err != nil || !bytes.HasPrefix(dosHeader, []byte("MZ")) {
+		return false
 	}
 
-	return len(n)
-}
-
-func validOffset(r io.ReaderAt, start int64) (bool, error) {
-	b := make([]byte, 3)
+	sig := make([]byte, 4)
 
-	_, err := r.ReadAt(b, start)
+	_, err = r.ReadAt(sig, int64(binary.LittleEndian.Uint32(dosHeader[0x3c:])))
 	if err != nil {
-		return false, errors.WithStack(err)
+		return false
 	}
 
-	return b[0] == 0x81 && b[1] == 0x7b && b[2] == 0x20, nil
+	return string(sig) == "PE\x00\x00"
 }
 
-type Entry struct {
-	MapName    string `json:"map_name"`
-	MapCrc32   uint32 `json:"map_crc"`
-	WorkshopID uint32 `json:"workshop_id"`
+// IsBinary checks whether b is a binary supported by ExtractCRCTable: an ELF file or a PE file with a valid PE signature.
+func IsBinary(b []byte) bool {
+	return bytes.HasPrefix(b, []byte(elf.ELFMAG)) || isPE(bytes.NewReader(b))
 }
 
-type Reader interface {
-	io.Reader
-	io.ReaderAt
+// LoadBinary loads bin/linux64/engine_client.so (ELF) or bin/engine.dll (PE), the format is detected from the file magic.
+// PE support is experimental: the layout of the CRC table in engine.dll hasn't been verified against a real build.
+func LoadBinary(r Reader) (*Binary, error) {
+	magic := make([]byte, 4)
+
+	_, err := r.ReadAt(magic, 0)
+	if err != nil && err != io.EOF {
+		return nil, errors.Wrap(err, "failed to read file magic")
+	}
+
+	switch {
+	case bytes.HasPrefix(magic, []byte(elf.ELFMAG)):
+		return loadELF(r)
+	case isPE(r):
+		return loadPE(r)
+	}
+
+	return nil, ErrUnknownFormat
 }
 
+// ExtractCRCTable extracts the table of map CRCs and the corresponding workshop items
+// from bin/linux64/engine_client.so (ELF) or bin/engine.dll (PE) using the registered strategies, see Extract.
+// All addresses are resolved through the section headers, so the table is found wherever the linker placed the code and strings.
 func ExtractCRCTable(r Reader) ([]Entry, error) {
-	startB, err := hex.DecodeString("817b20c2d13eba0f84")
+	res, err := Extract(r)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
-	// 817b20c2d13eba0f
-	// 84
-	// 50220000
-	// 4531ed45
-	// 31e4
 
-	start, err := find(r, startB)
+	return res.Entries, nil
+}
+
+// extractCRCTable decodes the chain at the first match of sig.
+// If first is set sig matches the first compare of the chain, which is decoded separately, and the chain follows sig.
+// Otherwise sig matches the chain and a compare/jump pair right before it is decoded as the first compare.
+func extractCRCTable(img *Binary, sig pattern, first bool) ([]Entry, error) {
+	start, err := findSignature(img, sig)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		return nil, err
 	}
 
-	if start < 0 {
-		return nil, errors.New("start bytes not found")
-	}
+	var (
+		res  []Entry
+		addr = start
+	)
 
-	start += 11
+	if first {
+		e, err := readFirstEntry(img, start, sig.len())
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read first CRC table entry at %#x", start)
+		}
 
-	var (
-		dwordBuf = make([]byte, 4)
-		res      = []Entry{
-			{ // first entry is hardcoded as it doesn't match the pattern - but this is always the same entry
-				MapName:    "de_nuke",
-				MapCrc32:   3124679106,
-				WorkshopID: 157233767,
-			},
+		res = append(res, e)
+		addr += uint64(sig.len())
+	} else if prev, n, ok := precedingCompare(img, start); ok {
+		e, err := readFirstEntry(img, prev, n)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read first CRC table entry at %#x", prev)
 		}
-	)
 
-	ok, err := validOffset(r, start)
-	if err != nil {
-		return nil, errors.WithStack(err)
+		res = append(res, e)
 	}
 
-	if !ok {
-		return nil, errors.New("found start bytes, but they were not a valid offset")
+	if !isEntry(img, addr) {
+		return nil, errors.New("found start bytes, but they were not followed by a CRC compare")
 	}
 
-	for ok {
-		// fmt.Println("crc")
-		crcAddr := start + 3
-
-		_, err = r.ReadAt(dwordBuf, crcAddr)
+	for ; isEntry(img, addr); addr += entrySize {
+		e, err := readEntry(img, addr)
 		if err != nil {
-			return nil, errors.WithStack(err)
+			return nil, errors.Wrapf(err, "failed to read CRC table entry at %#x", addr)
 		}
 
-		// fmt.Println(hex.EncodeToString(dwordBuf))
-		crc := binary.LittleEndian.Uint32(dwordBuf)
-		// fmt.Println(crc)
+		res = append(res, e)
+	}
+
+	return res, nil
+}
 
-		// fmt.Println("jump-target")
-		baseAddr := start + 7
-		jumpTargetOffsetAddr := baseAddr + 2
+// findSignature returns the virtual address of the first match of sig in the executable sections.
+func findSignature(img *Binary, sig pattern) (uint64, error) {
+	for _, s := range img.Sections {
+		if !s.Exec {
+			continue
+		}
 
-		_, err = r.ReadAt(dwordBuf, jumpTargetOffsetAddr)
-		if err != nil {
-			return nil, errors.WithStack(err)
+		i := sig.find(s.Data)
+		if i >= 0 {
+			return s.Addr + uint64(i), nil
 		}
+	}
 
-		// fmt.Println(hex.EncodeToString(dwordBuf))
-		jumpTargetOffset := binary.LittleEndian.Uint32(dwordBuf)
+	return 0, errors.New("start bytes not found")
+}
 
-		// fmt.Println("map-offset")
-		mapOffsetAddr := baseAddr + int64(jumpTargetOffset) + 16
-		// fmt.Println(mapOffsetAddr)
-		_, err = r.ReadAt(dwordBuf, mapOffsetAddr)
-		if err != nil {
-			return nil, errors.WithStack(err)
+// knownFirstEntry is the first entry of the table in all engine_client.so builds it was checked against.
+var knownFirstEntry = Entry{
+	MapName:    "de_nuke",
+	MapCrc32:   3124679106,
+	WorkshopID: 157233767,
+}
+
+// precedingCompare finds a `cmp dword [rbx+0x20], imm32; je` pair ending at most maxFirstCompareGap bytes before addr
+// and returns its address and length.
+func precedingCompare(img *Binary, addr uint64) (uint64, int, bool) {
+	for gap := uint64(0); gap <= maxFirstCompareGap; gap++ {
+		// je rel8 and je rel32
+		for _, n := range []uint64{9, 13} {
+			if addr < gap+n {
+				continue
+			}
+
+			start := addr - gap - n
+
+			b, err := img.Read(start, int(n))
+			if err != nil || !bytes.HasPrefix(b, []byte{0x81, 0x7b, 0x20}) {
+				continue
+			}
+
+			if _, jeLen, ok := decodeJe(b[7:]); ok && uint64(jeLen) == n-7 {
+				return start, int(n), true
+			}
 		}
+	}
 
-		// fmt.Println(hex.EncodeToString(dwordBuf))
-		mapAddr := binary.LittleEndian.Uint32(dwordBuf) + 4 + uint32(mapOffsetAddr)
+	return 0, 0, false
+}
 
-		// fmt.Println("map")
+// readFirstEntry decodes the compare/jump pair matched by the signature.
+// The code at its jump target doesn't follow the fixed layout of the chain and decoding it hasn't been verified against a real build,
+// so knownFirstEntry is used if the CRC matches and any other target is only accepted if it can be decoded unambiguously.
+func readFirstEntry(img *Binary, addr uint64, n int) (Entry, error) {
+	b, err := img.Read(addr, n)
+	if err != nil {
+		return Entry{}, err
+	}
 
-		const mapNameMaxLength = 64
+	crc, _, cmpLen, ok := decodeCmp(b, img.Is64)
+	if !ok {
+		return Entry{}, errors.New("failed to decode CRC compare")
+	}
 
-		mapNameBuf := make([]byte, mapNameMaxLength)
+	if crc == knownFirstEntry.MapCrc32 {
+		return knownFirstEntry, nil
+	}
 
-		_, err = r.ReadAt(mapNameBuf, int64(mapAddr))
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+	rel, jeLen, ok := decodeJe(b[cmpLen:])
+	if !ok {
+		return Entry{}, errors.New("failed to decode jump")
+	}
 
-		mapName := string(mapNameBuf[:clen(mapNameBuf)])
-		// fmt.Println(crc, mapName)
+	// rel is relative to the end of the je instruction
+	target := uint64(int64(addr) + int64(cmpLen+jeLen) + rel)
 
-		// fmt.Println("workshop-id")
-		workshopIDAddr := baseAddr + int64(jumpTargetOffset) + 33
+	mapName, workshopID, err := decodeFirstTarget(img, target)
+	if err != nil {
+		return Entry{}, err
+	}
 
-		_, err = r.ReadAt(dwordBuf, workshopIDAddr)
-		if err != nil {
-			return nil, errors.WithStack(err)
+	return Entry{
+		MapName:    mapName,
+		MapCrc32:   crc,
+		WorkshopID: workshopID,
+	}, nil
+}
+
+// decodeFirstTarget decodes the jump target of the first compare.
+// Unlike decodeTarget it doesn't pick the first candidates, the target has to load exactly one map name and one non-zero workshop ID.
+func decodeFirstTarget(img *Binary, addr uint64) (mapName string, workshopID uint32, err error) {
+	var (
+		names []string
+		ids   []uint32
+	)
+
+	for _, v := range targetValues(img, addr) {
+		if name, ok := mapNameAt(img, v.v); ok {
+			names = append(names, name)
+		} else if !v.addr {
+			ids = append(ids, uint32(v.v))
 		}
+	}
 
-		// fmt.Println(hex.EncodeToString(dwordBuf))
-		workshopID := binary.LittleEndian.Uint32(dwordBuf)
-		// fmt.Println(start, crc, mapName, workshopID)
+	switch {
+	case len(names) != 1:
+		return "", 0, errors.Errorf("expected one map name loaded at %#x, found %d", addr, len(names))
+	case len(ids) != 1:
+		return "", 0, errors.Errorf("expected one workshop ID loaded at %#x, found %d", addr, len(ids))
+	case ids[0] == 0:
+		return "", 0, errors.Errorf("workshop ID loaded at %#x is 0", addr)
+	}
 
-		res = append(res, Entry{
-			MapName:    mapName,
-			MapCrc32:   crc,
-			WorkshopID: workshopID,
-		})
+	return names[0], ids[0], nil
+}
 
-		start += 13
-		ok, err = validOffset(r, start)
-		if err != nil {
-			return nil, errors.WithStack(err)
-		}
+// isEntry checks whether addr is the start of a `cmp dword [rbx+0x20], imm32` instruction.
+func isEntry(img *Binary, addr uint64) bool {
+	b, err := img.Read(addr, 3)
+	if err != nil {
+		return false
 	}
 
-	return res, nil
+	return b[0] == 0x81 && b[1] == 0x7b && b[2] == 0x20
+}
+
+// readEntry decodes the compare/jump pair at addr and the code at the jump target, which loads the map name and workshop ID.
+func readEntry(img *Binary, addr uint64) (Entry, error) {
+	crc, err := img.Uint32(addr + 3)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to read CRC")
+	}
+
+	rel, err := img.Uint32(addr + 9)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to read jump offset")
+	}
+
+	// rel32 is relative to the end of the je instruction
+	target := addr + entrySize + uint64(int64(int32(rel)))
+
+	disp, err := img.Uint32(target + mapNameDispOffset)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to read map name offset")
+	}
+
+	// disp32 is relative to the end of the displacement (RIP-relative addressing)
+	mapNameAddr := target + mapNameDispOffset + 4 + uint64(int64(int32(disp)))
+
+	mapName, err := img.CString(mapNameAddr, mapNameMaxLength)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to read map name")
+	}
+
+	workshopID, err := img.Uint32(target + workshopIDOffset)
+	if err != nil {
+		return Entry{}, errors.Wrap(err, "failed to read workshop ID")
+	}
+
+	return Entry{
+		MapName:    mapName,
+		MapCrc32:   crc,
+		WorkshopID: workshopID,
+	}, nil
 }
@@ -0,0 +1,300 @@
+package crc_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+)
+
+const (
+	textAddr   = 0x401000
+	rodataAddr = 0x7a0000
+	// targetSize is the size of the code at each jump target that loads the map name and workshop ID.
+	targetSize = 32
+)
+
+var chain = []crc.Entry{
+	{MapName: "de_dust2", MapCrc32: 1182019033, WorkshopID: 157435589},
+	{MapName: "cs_agency", MapCrc32: 2230463619, WorkshopID: 259816283},
+	{MapName: "de_cache", MapCrc32: 2895852907, WorkshopID: 2497723828},
+}
+
+func le32(v uint32) []byte {
+	b := make([]byte, 4)
+	binary.LittleEndian.PutUint32(b, v)
+
+	return b
+}
+
+// target returns the code at a jump target of the chain, which loads the map name (RIP-relative) and the workshop ID.
+func target(addr, mapNameAddr uint64, workshopID uint32) []byte {
+	var b bytes.Buffer
+
+	b.Write(bytes.Repeat([]byte{0x90}, 7))
+	b.Write([]byte{0x48, 0x8d, 0x35}) // lea rsi, [rip+disp32]
+	b.Write(le32(uint32(int32(mapNameAddr - (addr + 14)))))
+	b.Write(bytes.Repeat([]byte{0x90}, 12))
+	b.WriteByte(0xbe) // mov esi, imm32
+	b.Write(le32(workshopID))
+	b.WriteByte(0xc3)
+
+	return b.Bytes()
+}
+
+// firstTarget returns the code at the jump target of the first compare, which loads the workshop ID before the map name.
+// The encoding is synthetic and not taken from the disassembly of a real engine_client.so,
+// which is why de_nuke, the first entry of all real tables, isn't decoded from it.
+func firstTarget(addr, mapNameAddr uint64, workshopID uint32) []byte {
+	var b bytes.Buffer
+
+	b.Write([]byte{0x41, 0xbc}) // mov r12d, imm32
+	b.Write(le32(workshopID))
+	b.Write([]byte{0x48, 0x8d, 0x3d}) // lea rdi, [rip+disp32]
+	b.Write(le32(uint32(int32(mapNameAddr - (addr + 13)))))
+	b.Write([]byte{0xe9, 0, 0, 0, 0}) // jmp
+
+	return b.Bytes()
+}
+
+// engineClient builds an ELF binary containing the CRC compare chain for entries.
+// The first entry is compared by the signature, using je rel8 if shortJump is set and je rel32 otherwise.
+// The jump targets of the chain are placed before it, so those jumps are backwards.
+func engineClient(entries []crc.Entry, shortJump bool) []byte {
+	var (
+		text   bytes.Buffer
+		rodata bytes.Buffer
+		names  []uint64
+	)
+
+	for _, e := range entries {
+		names = append(names, rodataAddr+uint64(rodata.Len()))
+		rodata.WriteString(e.MapName)
+		rodata.WriteByte(0)
+	}
+
+	text.Write(bytes.Repeat([]byte{0xcc}, 16))
+
+	targets := make([]uint64, len(entries))
+	for i := 1; i < len(entries); i++ {
+		targets[i] = textAddr + uint64(text.Len())
+		text.Write(target(targets[i], names[i], entries[i].WorkshopID))
+	}
+
+	// the first target follows the chain, the xors and the final ret
+	chainLen := uint64(6 + (len(entries)-1)*13 + 1)
+
+	text.Write([]byte{0x81, 0x7b, 0x20})
+	text.Write(le32(entries[0].MapCrc32))
+
+	if shortJump {
+		text.Write([]byte{0x74, byte(chainLen)})
+	} else {
+		text.Write([]byte{0x0f, 0x84})
+		text.Write(le32(uint32(chainLen)))
+	}
+
+	targets[0] = textAddr + uint64(text.Len()) + chainLen
+
+	text.Write([]byte{0x45, 0x31, 0xed, 0x45, 0x31, 0xe4})
+
+	for i := 1; i < len(entries); i++ {
+		addr := textAddr + uint64(text.Len())
+
+		text.Write([]byte{0x81, 0x7b, 0x20})
+		text.Write(le32(entries[i].MapCrc32))
+		text.Write([]byte{0x0f, 0x84})
+		text.Write(le32(uint32(int32(targets[i] - (addr + 13)))))
+	}
+
+	text.WriteByte(0xc3)
+	text.Write(firstTarget(targets[0], names[0], entries[0].WorkshopID))
+
+	return fixture.ELF(
+		fixture.Section{Name: ".rodata", Addr: rodataAddr, Data: rodata.Bytes()},
+		fixture.Section{Name: ".text", Addr: textAddr, Data: text.Bytes(), Exec: true},
+	)
+}
+
+func TestExtractCRCTable(t *testing.T) {
+	entries := append([]crc.Entry{nuke}, chain...)
+
+	for _, shortJump := range []bool{false, true} {
+		tab, err := crc.ExtractCRCTable(bytes.NewReader(engineClient(entries, shortJump)))
+		assert.NoError(t, err)
+		assert.Equal(t, entries, tab)
+	}
+}
+
+func TestExtractCRCTableDecodesFirstEntry(t *testing.T) {
+	first := crc.Entry{MapName: "de_vertigo", MapCrc32: 42, WorkshopID: 43}
+	entries := append([]crc.Entry{first}, chain...)
+
+	tab, err := crc.ExtractCRCTable(bytes.NewReader(engineClient(entries, false)))
+	assert.NoError(t, err)
+	assert.Equal(t, entries, tab)
+}
+
+func TestExtractKnownFirstEntry(t *testing.T) {
+	// the target of the de_nuke compare isn't decoded
+	first := crc.Entry{MapName: "cs_nuke", MapCrc32: nuke.MapCrc32}
+
+	tab, err := crc.ExtractCRCTable(bytes.NewReader(engineClient(append([]crc.Entry{first}, chain...), false)))
+	assert.NoError(t, err)
+	assert.Equal(t, append([]crc.Entry{nuke}, chain...), tab)
+}
+
+func TestExtractFirstEntryNotDecodable(t *testing.T) {
+	first := crc.Entry{MapName: "de_vertigo", MapCrc32: 42}
+
+	bin, err := crc.LoadBinary(bytes.NewReader(engineClient(append([]crc.Entry{first}, chain...), false)))
+	assert.NoError(t, err)
+
+	_, err = crc.ExtractFrom(bin, crc.Strategies()[0])
+	assert.ErrorIs(t, err, crc.ErrNotFound)
+	assert.Contains(t, err.Error(), "failed to read first CRC table entry")
+	assert.Contains(t, err.Error(), "is 0")
+}
+
+func TestExtractSuspiciousEntry(t *testing.T) {
+	entries := append([]crc.Entry{nuke}, chain[0], crc.Entry{MapName: "de_mirage", MapCrc32: 1})
+
+	res, err := crc.Extract(bytes.NewReader(engineClient(entries, false)))
+	assert.NoError(t, err)
+	assert.Equal(t, entries, res.Entries)
+	assert.Equal(t, []string{"entry 2 (de_mirage): workshop ID is 0"}, res.Warnings)
+
+	res, err = crc.Extract(bytes.NewReader(engineClient(append([]crc.Entry{nuke}, chain...), false)))
+	assert.NoError(t, err)
+	assert.Empty(t, res.Warnings)
+}
+
+func TestExtractCRCTableNotFound(t *testing.T) {
+	bin := fixture.ELF(fixture.Section{Name: ".text", Addr: textAddr, Data: []byte{0x90, 0xc3}, Exec: true})
+
+	_, err := crc.ExtractCRCTable(bytes.NewReader(bin))
+	assert.ErrorIs(t, err, crc.ErrNotFound)
+	assert.Contains(t, err.Error(), "signature-je-rel32: start bytes not found")
+	assert.Contains(t, err.Error(), "heuristic-x86: no CRC compare chain found")
+}
+
+// engineDLL builds a 32-bit PE binary containing a CRC compare chain for entries.
+// The layout is synthetic and not taken from a real bin/engine.dll, which is why PE support is documented as experimental:
+// the code at the jump targets pushes the workshop ID and the absolute address of the map name.
+func engineDLL(entries []crc.Entry) []byte {
+	const (
+		imageBase = 0x10000000
+		textRVA   = 0x1000
+		rdataRVA  = 0x3a0000
+	)
+
+	var (
+		text  bytes.Buffer
+		rdata bytes.Buffer
+		names []uint32
+	)
+
+	for _, e := range entries {
+		names = append(names, imageBase+rdataRVA+uint32(rdata.Len()))
+		rdata.WriteString(e.MapName)
+		rdata.WriteByte(0)
+	}
+
+	// a shorter chain that isn't the CRC table
+	text.Write([]byte{0x3d, 1, 0, 0, 0, 0x74, 0x10, 0x3d, 2, 0, 0, 0, 0x74, 0x0a, 0xc3})
+
+	var jumps []int
+
+	for _, e := range entries {
+		text.Write([]byte{0x81, 0x7e, 0x24}) // cmp dword [esi+0x24], imm32
+		text.Write(le32(e.MapCrc32))
+		text.Write([]byte{0x0f, 0x84})
+		jumps = append(jumps, text.Len())
+		text.Write(le32(0))
+	}
+
+	text.WriteByte(0xc3)
+
+	for i, e := range entries {
+		binary.LittleEndian.PutUint32(text.Bytes()[jumps[i]:], uint32(text.Len()-(jumps[i]+4)))
+
+		text.WriteByte(0x68) // push imm32
+		text.Write(le32(e.WorkshopID))
+		text.WriteByte(0x68)
+		text.Write(le32(names[i]))
+		text.Write([]byte{0xe8, 0, 0, 0, 0}) // call
+		text.WriteByte(0xc3)
+	}
+
+	return fixture.PE(imageBase,
+		fixture.Section{Name: ".text", Addr: textRVA, Data: text.Bytes(), Exec: true},
+		fixture.Section{Name: ".rdata", Addr: rdataRVA, Data: rdata.Bytes()},
+	)
+}
+
+func TestExtractCRCTablePE(t *testing.T) {
+	entries := append([]crc.Entry{nuke}, chain...)
+
+	tab, err := crc.ExtractCRCTable(bytes.NewReader(engineDLL(entries)))
+	assert.NoError(t, err)
+	assert.Equal(t, entries, tab)
+}
+
+func TestExtractCRCTableUnknownFormat(t *testing.T) {
+	_, err := crc.ExtractCRCTable(bytes.NewReader([]byte("not a binary")))
+	assert.ErrorIs(t, err, crc.ErrUnknownFormat)
+}
+
+func TestExtractCRCTablePERejectsNonTableChains(t *testing.T) {
+	_, err := crc.ExtractCRCTable(bytes.NewReader(engineDLL([]crc.Entry{nuke, chain[0], {MapName: "de_mirage", MapCrc32: 1, WorkshopID: 0}})))
+	assert.ErrorIs(t, err, crc.ErrNotFound)
+	assert.Contains(t, err.Error(), "doesn't look like the CRC table")
+
+	// strings that aren't map names aren't even decoded as map name
+	_, err = crc.ExtractCRCTable(bytes.NewReader(engineDLL([]crc.Entry{nuke, chain[0], {MapName: "m_flWeight", MapCrc32: 1, WorkshopID: 1}})))
+	assert.ErrorIs(t, err, crc.ErrNotFound)
+}
+
+func TestIsBinary(t *testing.T) {
+	assert.True(t, crc.IsBinary(engineDLL(chain)))
+	assert.True(t, crc.IsBinary(engineClient(chain, false)))
+	assert.False(t, crc.IsBinary([]byte("MZ is also how this text file starts")))
+
+	// MZ header without PE signature at e_lfanew
+	dos := make([]byte, 0x80)
+	copy(dos, "MZ")
+	binary.LittleEndian.PutUint32(dos[0x3c:], 0x40)
+	assert.False(t, crc.IsBinary(dos))
+
+	_, err := crc.ExtractCRCTable(bytes.NewReader(dos))
+	assert.ErrorIs(t, err, crc.ErrUnknownFormat)
+}
+
+// TestExtractRealTable builds binaries from the table in test/data, which contains duplicate CRCs.
+func TestExtractRealTable(t *testing.T) {
+	f, err := os.Open("../../test/data/crc_table.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	tab, err := crc.LoadTable(f)
+	assert.NoError(t, err)
+
+	entries := tab.Entries()
+
+	for name, bin := range map[string][]byte{
+		"engine_client.so": engineClient(entries, false),
+		"cmp ebx":          cmpEBXChain(entries),
+		"engine.dll":       engineDLL(entries),
+	} {
+		res, err := crc.Extract(bytes.NewReader(bin))
+		if assert.NoError(t, err, name) {
+			assert.Equal(t, entries, res.Entries, name)
+			assert.Empty(t, res.Warnings, name)
+		}
+	}
+}
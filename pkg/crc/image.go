@@ -0,0 +1,168 @@
+package crc
+
+import (
+	"debug/elf"
+	"debug/pe"
+	"encoding/binary"
+
+	"github.com/pkg/errors"
+)
+
+// Section is a loaded section of a binary.
+type Section struct {
+	Name string
+	// Addr is the virtual address the section is mapped at.
+	Addr uint64
+	Data []byte
+	// Exec is set for sections containing executable code.
+	Exec bool
+}
+
+func (s *Section) contains(addr uint64, n int) bool {
+	return addr >= s.Addr && addr+uint64(n) <= s.Addr+uint64(len(s.Data))
+}
+
+// Binary is the virtual memory view of a binary, addressed by virtual address instead of file offset.
+// Code references other sections (e.g. map name strings in .rodata) relative to its own virtual address,
+// which only corresponds to the file offset if both sections happen to be mapped with the same delta.
+type Binary struct {
+	Sections []Section
+	// Is64 is set for 64-bit (x86-64) binaries.
+	Is64 bool
+}
+
+// Section returns the section containing the n bytes at addr.
+func (img *Binary) Section(addr uint64, n int) (*Section, error) {
+	for i := range img.Sections {
+		if img.Sections[i].contains(addr, n) {
+			return &img.Sections[i], nil
+		}
+	}
+
+	return nil, errors.Errorf("address %#x (+%d bytes) is not in any loaded section", addr, n)
+}
+
+// Read returns the n bytes at addr.
+func (img *Binary) Read(addr uint64, n int) ([]byte, error) {
+	s, err := img.Section(addr, n)
+	if err != nil {
+		return nil, err
+	}
+
+	off := addr - s.Addr
+
+	return s.Data[off : off+uint64(n)], nil
+}
+
+// Uint32 reads a little endian uint32 at addr.
+func (img *Binary) Uint32(addr uint64) (uint32, error) {
+	b, err := img.Read(addr, 4)
+	if err != nil {
+		return 0, err
+	}
+
+	return binary.LittleEndian.Uint32(b), nil
+}
+
+// CString reads a NUL terminated string of at most maxLen bytes at addr.
+func (img *Binary) CString(addr uint64, maxLen int) (string, error) {
+	s, err := img.Section(addr, 1)
+	if err != nil {
+		return "", err
+	}
+
+	b := s.Data[addr-s.Addr:]
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+
+	return string(b[:clen(b)]), nil
+}
+
+func clen(n []byte) int {
+	for i := 0; i < len(n); i++ {
+		if n[i] == 0 {
+			return i
+		}
+	}
+
+	return len(n)
+}
+
+// loadELF loads all allocated sections of an ELF binary.
+func loadELF(r Reader) (*Binary, error) {
+	f, err := elf.NewFile(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse ELF file")
+	}
+
+	img := &Binary{Is64: f.Class == elf.ELFCLASS64}
+
+	for _, s := range f.Sections {
+		if s.Flags&elf.SHF_ALLOC == 0 || s.Type == elf.SHT_NOBITS || s.Size == 0 {
+			continue
+		}
+
+		data, err := s.Data()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read ELF section %q", s.Name)
+		}
+
+		img.Sections = append(img.Sections, Section{
+			Name: s.Name,
+			Addr: s.Addr,
+			Data: data,
+			Exec: s.Flags&elf.SHF_EXECINSTR != 0,
+		})
+	}
+
+	return img, nil
+}
+
+// loadPE loads all sections of a PE binary (Windows .dll / .exe) that have data in the file.
+// Section addresses include the image base, so absolute addresses in 32-bit code can be resolved directly.
+func loadPE(r Reader) (*Binary, error) {
+	f, err := pe.NewFile(r)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to parse PE file")
+	}
+
+	img := new(Binary)
+
+	var imageBase uint64
+
+	switch h := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		imageBase = uint64(h.ImageBase)
+	case *pe.OptionalHeader64:
+		imageBase = h.ImageBase
+		img.Is64 = true
+	default:
+		return nil, errors.New("PE file has no optional header")
+	}
+
+	for _, s := range f.Sections {
+		if s.Size == 0 {
+			continue
+		}
+
+		data, err := s.Data()
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to read PE section %q", s.Name)
+		}
+
+		// the raw data is padded to the file alignment, only VirtualSize bytes are actually mapped
+		if s.VirtualSize > 0 && int(s.VirtualSize) < len(data) {
+			data = data[:s.VirtualSize]
+		}
+
+		img.Sections = append(img.Sections, Section{
+			Name: s.Name,
+			Addr: imageBase + uint64(s.VirtualAddress),
+			Data: data,
+			Exec: s.Characteristics&pe.IMAGE_SCN_MEM_EXECUTE != 0,
+		})
+	}
+
+	return img, nil
+}
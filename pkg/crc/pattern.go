@@ -0,0 +1,88 @@
+package crc
+
+import (
+	"bytes"
+	"encoding/hex"
+	"strings"
+)
+
+// pattern is a byte pattern with wildcards.
+type pattern struct {
+	b    []byte
+	mask []bool
+}
+
+// mustPattern parses a pattern of space separated hex bytes, where ?? matches any byte, e.g. "81 7b 20 ?? ?? ?? ??".
+func mustPattern(s string) pattern {
+	var p pattern
+
+	for _, f := range strings.Fields(s) {
+		if f == "??" {
+			p.b = append(p.b, 0)
+			p.mask = append(p.mask, false)
+
+			continue
+		}
+
+		b, err := hex.DecodeString(f)
+		if err != nil || len(b) != 1 {
+			panic("invalid pattern byte " + f)
+		}
+
+		p.b = append(p.b, b[0])
+		p.mask = append(p.mask, true)
+	}
+
+	return p
+}
+
+func (p pattern) len() int {
+	return len(p.b)
+}
+
+// prefix returns the bytes up to the first wildcard.
+func (p pattern) prefix() []byte {
+	for i, m := range p.mask {
+		if !m {
+			return p.b[:i]
+		}
+	}
+
+	return p.b
+}
+
+func (p pattern) matchAt(data []byte) bool {
+	if len(data) < len(p.b) {
+		return false
+	}
+
+	for i, m := range p.mask {
+		if m && data[i] != p.b[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// find returns the offset of the first match in data or -1.
+func (p pattern) find(data []byte) int {
+	prefix := p.prefix()
+
+	for off := 0; off < len(data); {
+		i := bytes.Index(data[off:], prefix)
+		if i < 0 {
+			return -1
+		}
+
+		off += i
+
+		if p.matchAt(data[off:]) {
+			return off
+		}
+
+		off++
+	}
+
+	return -1
+}
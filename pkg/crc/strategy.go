@@ -0,0 +1,155 @@
+package crc
+
+import (
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Strategy finds and decodes the CRC table in a loaded binary.
+// Strategies are tried in the order they were registered (see RegisterStrategy), the first one to succeed wins.
+type Strategy interface {
+	// Name identifies the strategy, e.g. in log output.
+	Name() string
+	// Extract returns the CRC table or an error if the binary doesn't contain the code the strategy looks for.
+	Extract(bin *Binary) ([]Entry, error)
+}
+
+// signatureStrategy searches for a known byte pattern in the Linux engine_client.so.
+type signatureStrategy struct {
+	name string
+	sig  pattern
+	// first is set if sig matches the first compare of the chain, see extractCRCTable.
+	first bool
+}
+
+func (s signatureStrategy) Name() string {
+	return s.name
+}
+
+func (s signatureStrategy) Extract(bin *Binary) ([]Entry, error) {
+	// the chain uses RIP-relative addressing for the map names
+	if !bin.Is64 {
+		return nil, errors.New("not a 64-bit binary")
+	}
+
+	return extractCRCTable(bin, s.sig, s.first)
+}
+
+// heuristicStrategy decodes compare/jump chains instruction by instruction, see x86.go.
+type heuristicStrategy struct{}
+
+func (heuristicStrategy) Name() string {
+	return "heuristic-x86"
+}
+
+func (heuristicStrategy) Extract(bin *Binary) ([]Entry, error) {
+	return extractCRCTableHeuristic(bin)
+}
+
+// The signatures match the code comparing the map CRC against the first entry of the table, for example:
+//
+//	81 7b 20 c2 d1 3e ba    cmp dword [rbx+0x20], 0xba3ed1c2   ; de_nuke
+//	0f 84 xx xx xx xx       je  <de_nuke>
+//	45 31 ed                xor r13d, r13d
+//	45 31 e4                xor r12d, r12d
+//
+// followed by a chain of compare/jump pairs (see entrySize), one per map version.
+// The first jump may be encoded as je rel8 or je rel32 and its target loads the map name and workshop ID
+// in a different order than the targets of the chain, so it's decoded separately (see readFirstEntry).
+// signature-chain matches the chain itself, for builds where the registers aren't cleared between the compares,
+// a compare/jump pair shortly before the match is decoded like the first compare (see precedingCompare).
+//
+// The fixed signatures come first since they are the most specific, the heuristic works for both ELF and PE binaries.
+var (
+	strategiesMu sync.RWMutex
+	strategies   = []Strategy{
+		signatureStrategy{
+			name:  "signature-je-rel32",
+			sig:   mustPattern("81 7b 20 ?? ?? ?? ?? 0f 84 ?? ?? ?? ?? 45 31 ed 45 31 e4"),
+			first: true,
+		},
+		signatureStrategy{
+			name:  "signature-je-rel8",
+			sig:   mustPattern("81 7b 20 ?? ?? ?? ?? 74 ?? 45 31 ed 45 31 e4"),
+			first: true,
+		},
+		signatureStrategy{
+			name: "signature-chain",
+			sig:  mustPattern("81 7b 20 ?? ?? ?? ?? 0f 84 ?? ?? ?? ?? 81 7b 20"),
+		},
+		heuristicStrategy{},
+	}
+)
+
+// RegisterStrategy adds s to the strategies tried by Extract, after all strategies registered before.
+func RegisterStrategy(s Strategy) {
+	strategiesMu.Lock()
+	defer strategiesMu.Unlock()
+
+	strategies = append(strategies, s)
+}
+
+// Strategies returns the registered strategies in the order they are tried.
+func Strategies() []Strategy {
+	strategiesMu.RLock()
+	defer strategiesMu.RUnlock()
+
+	return append([]Strategy(nil), strategies...)
+}
+
+// ErrNotFound is returned by Extract if none of the strategies could extract the CRC table.
+var ErrNotFound = errors.New("CRC table not found")
+
+// notFoundError lists the error of each strategy that was tried.
+type notFoundError []string
+
+func (e notFoundError) Error() string {
+	return ErrNotFound.Error() + ", tried " + strings.Join(e, "; ")
+}
+
+func (e notFoundError) Is(target error) bool {
+	return target == ErrNotFound
+}
+
+// Result is the CRC table extracted from a binary.
+type Result struct {
+	Entries []Entry
+	// Strategy is the name of the strategy that extracted the table.
+	Strategy string
+	// Warnings describe entries that don't look like CRC table entries (see checkEntries),
+	// e.g. a zero workshop ID decoded from the chain by a signature strategy.
+	Warnings []string
+}
+
+// Extract loads the binary and tries all registered strategies in order, returning the table of the first one that succeeds.
+// If all strategies fail the returned error matches ErrNotFound and contains the error of each strategy.
+func Extract(r Reader) (*Result, error) {
+	bin, err := LoadBinary(r)
+	if err != nil {
+		return nil, err
+	}
+
+	return ExtractFrom(bin, Strategies()...)
+}
+
+// ExtractFrom tries the given strategies in order on an already loaded binary.
+func ExtractFrom(bin *Binary, strategies ...Strategy) (*Result, error) {
+	var errs notFoundError
+
+	for _, s := range strategies {
+		entries, err := s.Extract(bin)
+		if err == nil {
+			return &Result{
+				Entries:  entries,
+				Strategy: s.Name(),
+				Warnings: checkEntries(entries),
+			}, nil
+		}
+
+		errs = append(errs, s.Name()+": "+err.Error())
+	}
+
+	return nil, errs
+}
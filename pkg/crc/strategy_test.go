@@ -0,0 +1,152 @@
+package crc_test
+
+import (
+	"bytes"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+)
+
+// cmpEBXChain builds an ELF binary with a `cmp ebx, imm32; je rel32` chain that none of the signatures match.
+// The code at the jump targets loads the workshop ID and the map name (RIP-relative) and calls a function.
+func cmpEBXChain(entries []crc.Entry) []byte {
+	var (
+		text   bytes.Buffer
+		rodata bytes.Buffer
+		names  []uint64
+	)
+
+	for _, e := range entries {
+		names = append(names, rodataAddr+uint64(rodata.Len()))
+		rodata.WriteString(e.MapName)
+		rodata.WriteByte(0)
+	}
+
+	var jumps []int
+
+	for _, e := range entries {
+		text.Write([]byte{0x81, 0xfb}) // cmp ebx, imm32
+		text.Write(le32(e.MapCrc32))
+		text.Write([]byte{0x0f, 0x84})
+		jumps = append(jumps, text.Len())
+		text.Write(le32(0))
+	}
+
+	text.WriteByte(0xc3)
+
+	for i, e := range entries {
+		le := le32(uint32(text.Len() - (jumps[i] + 4)))
+		copy(text.Bytes()[jumps[i]:], le)
+
+		addr := textAddr + uint64(text.Len())
+
+		text.WriteByte(0xbe) // mov esi, imm32
+		text.Write(le32(e.WorkshopID))
+		text.Write([]byte{0x48, 0x8d, 0x3d}) // lea rdi, [rip+disp32]
+		text.Write(le32(uint32(int32(names[i] - (addr + 12)))))
+		text.Write([]byte{0xe8, 0, 0, 0, 0}) // call
+	}
+
+	return fixture.ELF(
+		fixture.Section{Name: ".rodata", Addr: rodataAddr, Data: rodata.Bytes()},
+		fixture.Section{Name: ".text", Addr: textAddr, Data: text.Bytes(), Exec: true},
+	)
+}
+
+func TestExtractStrategy(t *testing.T) {
+	entries := append([]crc.Entry{nuke}, chain...)
+
+	cases := []struct {
+		name     string
+		bin      []byte
+		strategy string
+	}{
+		{name: "je rel32", bin: engineClient(entries, false), strategy: "signature-je-rel32"},
+		{name: "je rel8", bin: engineClient(entries, true), strategy: "signature-je-rel8"},
+		{name: "cmp ebx", bin: cmpEBXChain(entries), strategy: "heuristic-x86"},
+		{name: "engine.dll", bin: engineDLL(entries), strategy: "heuristic-x86"},
+	}
+
+	for _, c := range cases {
+		res, err := crc.Extract(bytes.NewReader(c.bin))
+		if assert.NoError(t, err, c.name) {
+			assert.Equal(t, entries, res.Entries, c.name)
+			assert.Equal(t, c.strategy, res.Strategy, c.name)
+		}
+	}
+}
+
+// withoutRegisterClearing replaces the xors after the first compare, so only signature-chain matches.
+func withoutRegisterClearing(bin []byte) []byte {
+	return bytes.Replace(bin, []byte{0x45, 0x31, 0xed, 0x45, 0x31, 0xe4}, []byte{0x90, 0x90, 0x90, 0x90, 0x90, 0x90}, 1)
+}
+
+func TestExtractFromSignatureChain(t *testing.T) {
+	// the first compare isn't part of the match, it's decoded from the bytes before it
+	entries := append([]crc.Entry{{MapName: "de_vertigo", MapCrc32: 42, WorkshopID: 43}}, chain...)
+
+	res, err := crc.Extract(bytes.NewReader(withoutRegisterClearing(engineClient(entries, false))))
+	assert.NoError(t, err)
+	assert.Equal(t, "signature-chain", res.Strategy)
+	assert.Equal(t, entries, res.Entries)
+
+	res, err = crc.Extract(bytes.NewReader(withoutRegisterClearing(engineClient(append([]crc.Entry{nuke}, chain...), true))))
+	assert.NoError(t, err)
+	assert.Equal(t, "signature-chain", res.Strategy)
+	assert.Equal(t, append([]crc.Entry{nuke}, chain...), res.Entries)
+}
+
+func TestExtractFromSignatureChainFirstEntryNotDecodable(t *testing.T) {
+	bin, err := crc.LoadBinary(bytes.NewReader(withoutRegisterClearing(engineClient(append([]crc.Entry{{MapName: "de_vertigo", MapCrc32: 42}}, chain...), false))))
+	assert.NoError(t, err)
+
+	// the table isn't returned without its first entry
+	_, err = crc.ExtractFrom(bin, crc.Strategies()[2])
+	assert.ErrorIs(t, err, crc.ErrNotFound)
+	assert.Contains(t, err.Error(), "signature-chain: failed to read first CRC table entry")
+}
+
+type fakeStrategy struct {
+	name    string
+	entries []crc.Entry
+	err     error
+}
+
+func (s fakeStrategy) Name() string {
+	return s.name
+}
+
+func (s fakeStrategy) Extract(*crc.Binary) ([]crc.Entry, error) {
+	return s.entries, s.err
+}
+
+func TestExtractFrom(t *testing.T) {
+	bin, err := crc.LoadBinary(bytes.NewReader(engineClient(append([]crc.Entry{nuke}, chain...), false)))
+	assert.NoError(t, err)
+
+	res, err := crc.ExtractFrom(bin,
+		fakeStrategy{name: "broken", err: errors.New("nope")},
+		fakeStrategy{name: "fake", entries: chain},
+		fakeStrategy{name: "unused", entries: []crc.Entry{nuke}},
+	)
+	assert.NoError(t, err)
+	assert.Equal(t, &crc.Result{Entries: chain, Strategy: "fake"}, res)
+
+	_, err = crc.ExtractFrom(bin, fakeStrategy{name: "broken", err: errors.New("nope")})
+	assert.ErrorIs(t, err, crc.ErrNotFound)
+	assert.EqualError(t, err, "CRC table not found, tried broken: nope")
+}
+
+func TestStrategies(t *testing.T) {
+	var names []string
+
+	for _, s := range crc.Strategies() {
+		names = append(names, s.Name())
+	}
+
+	assert.Equal(t, []string{"signature-je-rel32", "signature-je-rel8", "signature-chain", "heuristic-x86"}, names)
+}
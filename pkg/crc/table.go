@@ -0,0 +1,148 @@
+package crc
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Table is an indexed CRC table.
+type Table struct {
+	entries      []Entry
+	byCRC        map[uint32]int
+	byMapName    map[string][]int
+	byWorkshopID map[uint32][]int
+}
+
+// NewTable creates an indexed table from entries.
+func NewTable(entries []Entry) *Table {
+	t := new(Table)
+	t.index(entries)
+
+	return t
+}
+
+func (t *Table) index(entries []Entry) {
+	t.entries = entries
+	t.byCRC = make(map[uint32]int, len(entries))
+	t.byMapName = make(map[string][]int)
+	t.byWorkshopID = make(map[uint32][]int, len(entries))
+
+	for i, e := range entries {
+		if _, ok := t.byCRC[e.MapCrc32]; !ok {
+			t.byCRC[e.MapCrc32] = i
+		}
+
+		t.byMapName[e.MapName] = append(t.byMapName[e.MapName], i)
+		t.byWorkshopID[e.WorkshopID] = append(t.byWorkshopID[e.WorkshopID], i)
+	}
+}
+
+func (t *Table) lookup(idx []int) []Entry {
+	res := make([]Entry, len(idx))
+	for i, j := range idx {
+		res[i] = t.entries[j]
+	}
+
+	return res
+}
+
+// Entries returns all entries in the order they were added.
+func (t *Table) Entries() []Entry {
+	return t.entries
+}
+
+// Len returns the number of entries.
+func (t *Table) Len() int {
+	return len(t.entries)
+}
+
+// ByCRC returns the entry for a map CRC.
+// If there are multiple entries with the same CRC, the first one is returned.
+func (t *Table) ByCRC(mapCRC uint32) (Entry, bool) {
+	i, ok := t.byCRC[mapCRC]
+	if !ok {
+		return Entry{}, false
+	}
+
+	return t.entries[i], true
+}
+
+// ByMapName returns all versions of a map.
+func (t *Table) ByMapName(mapName string) []Entry {
+	return t.lookup(t.byMapName[mapName])
+}
+
+// ByWorkshopID returns all entries that reference a workshop item.
+func (t *Table) ByWorkshopID(workshopID uint32) []Entry {
+	return t.lookup(t.byWorkshopID[workshopID])
+}
+
+// UnmarshalJSON decodes both the output of the crc-table command (`[]Entry`) and `{"maps": []Entry}`.
+func (t *Table) UnmarshalJSON(b []byte) error {
+	var entries []Entry
+
+	if bytes.HasPrefix(bytes.TrimSpace(b), []byte("[")) {
+		err := json.Unmarshal(b, &entries)
+		if err != nil {
+			return err
+		}
+	} else {
+		var wrapped struct {
+			Maps []Entry `json:"maps"`
+		}
+
+		err := json.Unmarshal(b, &wrapped)
+		if err != nil {
+			return err
+		}
+
+		entries = wrapped.Maps
+	}
+
+	t.index(entries)
+
+	return nil
+}
+
+// MarshalJSON encodes the table as `[]Entry`, like the crc-table command.
+func (t *Table) MarshalJSON() ([]byte, error) {
+	if t.entries == nil {
+		return []byte("[]"), nil
+	}
+
+	return json.Marshal(t.entries)
+}
+
+// LoadTable reads a CRC table in either of the formats supported by UnmarshalJSON.
+func LoadTable(r io.Reader) (*Table, error) {
+	t := new(Table)
+
+	err := json.NewDecoder(r).Decode(t)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode CRC table as JSON")
+	}
+
+	return t, nil
+}
+
+// Save writes the table as JSON.
+// If wrapped is true it's written as `{"maps": []Entry}` instead of `[]Entry`.
+func (t *Table) Save(w io.Writer, wrapped bool) error {
+	var v interface{} = t
+
+	if wrapped {
+		v = struct {
+			Maps *Table `json:"maps"`
+		}{t}
+	}
+
+	err := json.NewEncoder(w).Encode(v)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode CRC table as JSON")
+	}
+
+	return nil
+}
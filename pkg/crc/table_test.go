@@ -0,0 +1,67 @@
+package crc_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+)
+
+var nuke = crc.Entry{MapName: "de_nuke", MapCrc32: 3124679106, WorkshopID: 157233767}
+
+func TestLoadTable(t *testing.T) {
+	f, err := os.Open("../../test/data/crc_table.json")
+	assert.NoError(t, err)
+	defer f.Close()
+
+	tab, err := crc.LoadTable(f)
+	assert.NoError(t, err)
+	assert.Equal(t, 459, tab.Len())
+
+	e, ok := tab.ByCRC(nuke.MapCrc32)
+	assert.True(t, ok)
+	assert.Equal(t, nuke, e)
+
+	_, ok = tab.ByCRC(1)
+	assert.False(t, ok)
+
+	assert.Equal(t, []crc.Entry{nuke}, tab.ByWorkshopID(nuke.WorkshopID))
+	assert.Empty(t, tab.ByWorkshopID(1))
+
+	caches := tab.ByMapName("de_cache")
+	assert.Greater(t, len(caches), 1)
+
+	for _, e := range caches {
+		assert.Equal(t, "de_cache", e.MapName)
+	}
+
+	assert.Empty(t, tab.ByMapName("de_nonexistent"))
+}
+
+func TestTableFormats(t *testing.T) {
+	entries := []crc.Entry{
+		nuke,
+		{MapName: "de_cache", MapCrc32: 2895852907, WorkshopID: 2497723828},
+	}
+
+	for _, wrapped := range []bool{false, true} {
+		var buf bytes.Buffer
+
+		err := crc.NewTable(entries).Save(&buf, wrapped)
+		assert.NoError(t, err)
+		assert.Equal(t, wrapped, strings.HasPrefix(buf.String(), `{"maps":[`))
+
+		tab, err := crc.LoadTable(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, entries, tab.Entries())
+	}
+}
+
+func TestLoadTableInvalid(t *testing.T) {
+	_, err := crc.LoadTable(strings.NewReader(`{"maps": 1}`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,464 @@
+package crc
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// This file implements a small heuristic x86 / x86-64 decoder for CRC compare chains:
+//
+//	cmp <operand>, imm32    ; map CRC
+//	je  <target>            ; rel8 or rel32
+//	cmp <operand>, imm32
+//	je  <target>
+//	...
+//
+// where the code at each target loads the map name (as absolute address or RIP-relative lea) and the workshop ID (as immediate).
+// Only the instructions that commonly appear in these chains are decoded, anything else ends a chain or target.
+
+const (
+	// minChainLength is the minimum number of compare/jump pairs for a chain to be considered the CRC table.
+	minChainLength = 3
+	// maxTargetLength is the maximum number of bytes decoded at a jump target.
+	maxTargetLength = 64
+)
+
+// modRMLen returns the length of a ModRM byte including SIB byte and displacement.
+func modRMLen(b []byte) (int, bool) {
+	if len(b) < 1 {
+		return 0, false
+	}
+
+	var (
+		mod = b[0] >> 6
+		rm  = b[0] & 7
+		n   = 1
+	)
+
+	if mod != 3 && rm == 4 {
+		if len(b) < 2 {
+			return 0, false
+		}
+
+		n++
+
+		if mod == 0 && b[1]&7 == 5 {
+			n += 4
+		}
+	}
+
+	switch mod {
+	case 0:
+		if rm == 5 {
+			n += 4
+		}
+	case 1:
+		n++
+	case 2:
+		n += 4
+	}
+
+	if len(b) < n {
+		return 0, false
+	}
+
+	return n, true
+}
+
+// decodeCmp decodes `cmp r/m32, imm32` or `cmp eax, imm32`.
+// operand identifies the compared register / memory operand, it's the same for all compares of a chain.
+func decodeCmp(b []byte, is64 bool) (imm uint32, operand string, n int, ok bool) {
+	var rex []byte
+
+	if is64 && len(b) > 0 && b[0]&0xf0 == 0x40 {
+		// REX.W would make it a 64-bit compare
+		if b[0]&0x08 != 0 {
+			return 0, "", 0, false
+		}
+
+		rex, b = b[:1], b[1:]
+	}
+
+	switch {
+	case len(b) >= 5 && b[0] == 0x3d:
+		return binary.LittleEndian.Uint32(b[1:]), string(append(rex, b[0])), len(rex) + 5, true
+
+	case len(b) >= 2 && b[0] == 0x81 && (b[1]>>3)&7 == 7:
+		m, ok := modRMLen(b[1:])
+		if !ok || len(b) < 1+m+4 {
+			return 0, "", 0, false
+		}
+
+		return binary.LittleEndian.Uint32(b[1+m:]), string(append(rex, b[1:1+m]...)), len(rex) + 1 + m + 4, true
+	}
+
+	return 0, "", 0, false
+}
+
+// decodeJe decodes `je rel8` or `je rel32`.
+func decodeJe(b []byte) (rel int64, n int, ok bool) {
+	switch {
+	case len(b) >= 2 && b[0] == 0x74:
+		return int64(int8(b[1])), 2, true
+	case len(b) >= 6 && b[0] == 0x0f && b[1] == 0x84:
+		return int64(int32(binary.LittleEndian.Uint32(b[2:]))), 6, true
+	}
+
+	return 0, 0, false
+}
+
+// comparison is a decoded compare/jump pair.
+type comparison struct {
+	addr   uint64
+	crc    uint32
+	target uint64
+}
+
+// decodeChain decodes compare/jump pairs with the same operand starting at off in s.
+func decodeChain(s *Section, off int, is64 bool) []comparison {
+	var (
+		res   []comparison
+		first string
+	)
+
+	for off < len(s.Data) {
+		crc, operand, n, ok := decodeCmp(s.Data[off:], is64)
+		if !ok || (first != "" && operand != first) {
+			break
+		}
+
+		rel, m, ok := decodeJe(s.Data[off+n:])
+		if !ok {
+			break
+		}
+
+		first = operand
+		addr := s.Addr + uint64(off)
+		end := addr + uint64(n+m)
+
+		res = append(res, comparison{
+			addr:   addr,
+			crc:    crc,
+			target: uint64(int64(end) + rel),
+		})
+
+		off += n + m
+	}
+
+	return res
+}
+
+// cmpStart contains the first bytes of the compares decoded by decodeCmp: 0x3d, 0x81 or a REX prefix without W.
+var cmpStart = func() (res [256]bool) {
+	res[0x3d], res[0x81] = true, true
+
+	for b := 0x40; b <= 0x47; b++ {
+		res[b] = true
+	}
+
+	return res
+}()
+
+// findChains returns all compare/jump chains of at least minChainLength pairs in the executable sections, longest first.
+func findChains(img *Binary) [][]comparison {
+	var chains [][]comparison
+
+	for i := range img.Sections {
+		s := &img.Sections[i]
+		if !s.Exec {
+			continue
+		}
+
+		for off := 0; off < len(s.Data); {
+			if !cmpStart[s.Data[off]] {
+				off++
+				continue
+			}
+
+			chain := decodeChain(s, off, img.Is64)
+			if len(chain) >= minChainLength {
+				chains = append(chains, chain)
+				off = int(chain[len(chain)-1].addr-s.Addr) + 1
+
+				continue
+			}
+
+			off++
+		}
+	}
+
+	sort.SliceStable(chains, func(i, j int) bool {
+		return len(chains[i]) > len(chains[j])
+	})
+
+	return chains
+}
+
+// value is an immediate or address loaded by an instruction at a jump target.
+type value struct {
+	v uint64
+	// addr is true if v is an address computed by lea.
+	addr bool
+}
+
+// targetValues decodes the instructions at a jump target and returns the values they load, until a call, jump or unknown instruction.
+func targetValues(img *Binary, addr uint64) []value {
+	s, err := img.Section(addr, 1)
+	if err != nil {
+		return nil
+	}
+
+	b := s.Data[addr-s.Addr:]
+
+	if len(b) > maxTargetLength {
+		b = b[:maxTargetLength]
+	}
+
+	var (
+		res []value
+		off int
+	)
+
+	for off < len(b) {
+		var rexW bool
+
+		if img.Is64 && b[off]&0xf0 == 0x40 {
+			rexW = b[off]&0x08 != 0
+			off++
+
+			if off >= len(b) {
+				break
+			}
+		}
+
+		op := b[off]
+		off++
+
+		switch {
+		case op == 0x90, op >= 0x50 && op <= 0x5f:
+			// nop, push / pop reg
+
+		case op == 0x68 && off+4 <= len(b):
+			// push imm32
+			res = append(res, value{v: uint64(binary.LittleEndian.Uint32(b[off:]))})
+			off += 4
+
+		case op == 0x6a:
+			// push imm8
+			off++
+
+		case op >= 0xb8 && op <= 0xbf:
+			// mov r32, imm32 / mov r64, imm64
+			size := 4
+			if rexW {
+				size = 8
+			}
+
+			if off+size > len(b) {
+				return res
+			}
+
+			res = append(res, value{v: uint64(binary.LittleEndian.Uint32(b[off:]))})
+			off += size
+
+		case op == 0xc7:
+			// mov r/m32, imm32
+			m, ok := modRMLen(b[off:])
+			if !ok || off+m+4 > len(b) {
+				return res
+			}
+
+			res = append(res, value{v: uint64(binary.LittleEndian.Uint32(b[off+m:]))})
+			off += m + 4
+
+		case op == 0x8d:
+			// lea r, m
+			m, ok := modRMLen(b[off:])
+			if !ok {
+				return res
+			}
+
+			if b[off]>>6 == 0 && b[off]&7 == 5 {
+				disp := uint64(binary.LittleEndian.Uint32(b[off+1:]))
+
+				if img.Is64 {
+					// RIP-relative, relative to the end of the instruction
+					disp = addr + uint64(off+m) + uint64(int64(int32(disp)))
+				}
+
+				res = append(res, value{v: disp, addr: true})
+			}
+
+			off += m
+
+		case op == 0x01, op == 0x03, op == 0x29, op == 0x2b, op == 0x31, op == 0x33, op == 0x39, op == 0x3b, op == 0x85, op == 0x89, op == 0x8b:
+			// add, sub, xor, cmp, test, mov with ModRM operands
+			m, ok := modRMLen(b[off:])
+			if !ok {
+				return res
+			}
+
+			off += m
+
+		case op == 0x83:
+			// arithmetic r/m, imm8
+			m, ok := modRMLen(b[off:])
+			if !ok {
+				return res
+			}
+
+			off += m + 1
+
+		default:
+			// call, jmp, ret or anything we don't know
+			return res
+		}
+	}
+
+	return res
+}
+
+// isMapName checks whether b starts with a NUL terminated string that looks like a map name:
+// a lower case prefix of 2 to 5 letters (e.g. de, cs, coop), an underscore and the name (e.g. de_dust2).
+func isMapName(b []byte) bool {
+	n := clen(b)
+	if n < 4 || n >= len(b) || n >= mapNameMaxLength {
+		return false
+	}
+
+	prefix := bytes.IndexByte(b[:n], '_')
+	if prefix < 2 || prefix > 5 || prefix == n-1 {
+		return false
+	}
+
+	for i, c := range b[:n] {
+		switch {
+		case c >= 'a' && c <= 'z':
+		case i > prefix && (c >= '0' && c <= '9' || c == '_' || c == '-'):
+		case i == prefix:
+		default:
+			return false
+		}
+	}
+
+	return true
+}
+
+// checkEntries describes every entry that doesn't look like an entry of the CRC table: a map name that isn't one or a zero workshop ID.
+// Duplicate CRCs are fine, the real table contains some (e.g. de_cruise and cs_cruise) and the engine uses the first match like Table.ByCRC.
+func checkEntries(entries []Entry) []string {
+	var res []string
+
+	for i, e := range entries {
+		switch {
+		case !isMapName(append([]byte(e.MapName), 0)):
+			res = append(res, fmt.Sprintf("entry %d: %q is not a map name", i, e.MapName))
+		case e.WorkshopID == 0:
+			res = append(res, fmt.Sprintf("entry %d (%s): workshop ID is 0", i, e.MapName))
+		}
+	}
+
+	return res
+}
+
+// mapNameAt returns the map name at addr if there is one.
+func mapNameAt(img *Binary, addr uint64) (string, bool) {
+	s, err := img.Section(addr, 1)
+	if err != nil || s.Exec {
+		return "", false
+	}
+
+	b := s.Data[addr-s.Addr:]
+	if !isMapName(b) {
+		return "", false
+	}
+
+	return string(b[:clen(b)]), true
+}
+
+// decodeTarget finds the map name and workshop ID loaded by the code at a jump target.
+// The map name is the first value pointing to a map name string, the workshop ID is the first other immediate.
+func decodeTarget(img *Binary, addr uint64) (mapName string, workshopID uint32, err error) {
+	var (
+		foundName, foundID bool
+		values             = targetValues(img, addr)
+	)
+
+	for _, v := range values {
+		if name, ok := mapNameAt(img, v.v); ok {
+			if !foundName {
+				mapName, foundName = name, true
+			}
+
+			continue
+		}
+
+		if !foundID && !v.addr {
+			workshopID, foundID = uint32(v.v), true
+		}
+	}
+
+	if !foundName {
+		return "", 0, errors.Errorf("no map name loaded at %#x", addr)
+	}
+
+	if !foundID {
+		return "", 0, errors.Errorf("no workshop ID loaded at %#x", addr)
+	}
+
+	return mapName, workshopID, nil
+}
+
+// decodeEntries decodes the entries of a chain, it fails if any of the jump targets can't be decoded.
+func decodeEntries(img *Binary, chain []comparison) ([]Entry, error) {
+	res := make([]Entry, 0, len(chain))
+
+	for _, c := range chain {
+		name, id, err := decodeTarget(img, c.target)
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to decode jump target of CRC compare at %#x", c.addr)
+		}
+
+		res = append(res, Entry{
+			MapName:    name,
+			MapCrc32:   c.crc,
+			WorkshopID: id,
+		})
+	}
+
+	return res, nil
+}
+
+// extractCRCTableHeuristic returns the entries of the longest compare/jump chain whose targets can all be decoded
+// and whose entries look like CRC table entries (see checkEntries), so unrelated compare chains aren't mistaken for the table.
+func extractCRCTableHeuristic(img *Binary) ([]Entry, error) {
+	chains := findChains(img)
+	if len(chains) == 0 {
+		return nil, errors.New("no CRC compare chain found")
+	}
+
+	var firstErr error
+
+	for _, chain := range chains {
+		entries, err := decodeEntries(img, chain)
+		if err == nil {
+			problems := checkEntries(entries)
+			if len(problems) == 0 {
+				return entries, nil
+			}
+
+			err = errors.Errorf("compare chain at %#x doesn't look like the CRC table: %s", chain[0].addr, strings.Join(problems, "; "))
+		}
+
+		if firstErr == nil {
+			firstErr = err
+		}
+	}
+
+	return nil, errors.Wrap(firstErr, "no CRC compare chain could be decoded")
+}
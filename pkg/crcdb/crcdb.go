@@ -0,0 +1,151 @@
+// Package crcdb implements a database of map CRCs merged from the CRC tables of many engine_client.so builds.
+//
+// Each build only contains the map versions that were known when it was shipped,
+// so older versions can only be resolved by keeping the entries of past builds around.
+// The database is serialized as {"maps": [...]}, a superset of the CRC table format that adds first_seen/last_seen to each entry.
+package crcdb
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+)
+
+// Build identifies the engine_client.so build a CRC table was extracted from.
+type Build struct {
+	ID   string    `json:"id"`
+	Time time.Time `json:"time"`
+}
+
+// Record is a CRC table entry along with the first and last build it was seen in.
+type Record struct {
+	crc.Entry
+	FirstSeen Build `json:"first_seen"`
+	LastSeen  Build `json:"last_seen"`
+}
+
+// DB is a deduplicated collection of CRC table entries from many builds.
+// The zero value is an empty database.
+type DB struct {
+	Maps []Record `json:"maps"`
+
+	index map[crc.Entry]int
+}
+
+func (db *DB) record(e crc.Entry) (*Record, bool) {
+	if db.index == nil {
+		db.index = make(map[crc.Entry]int, len(db.Maps))
+
+		for i, r := range db.Maps {
+			db.index[r.Entry] = i
+		}
+	}
+
+	i, ok := db.index[e]
+	if !ok {
+		return nil, false
+	}
+
+	return &db.Maps[i], true
+}
+
+// Add adds the CRC table of a build.
+// Entries that are already in the database only have their first/last seen build updated.
+func (db *DB) Add(build Build, entries []crc.Entry) {
+	for _, e := range entries {
+		db.add(Record{Entry: e, FirstSeen: build, LastSeen: build})
+	}
+}
+
+// Merge adds all records of another database.
+func (db *DB) Merge(other *DB) {
+	for _, r := range other.Maps {
+		db.add(r)
+	}
+}
+
+func (db *DB) add(rec Record) {
+	r, ok := db.record(rec.Entry)
+	if !ok {
+		db.index[rec.Entry] = len(db.Maps)
+		db.Maps = append(db.Maps, rec)
+
+		return
+	}
+
+	if rec.FirstSeen.Time.Before(r.FirstSeen.Time) {
+		r.FirstSeen = rec.FirstSeen
+	}
+
+	// on equal times the build added last wins
+	if !rec.LastSeen.Time.Before(r.LastSeen.Time) {
+		r.LastSeen = rec.LastSeen
+	}
+}
+
+// Entries returns all entries in the database, in the same order as written by Save.
+func (db *DB) Entries() []crc.Entry {
+	db.sort()
+
+	entries := make([]crc.Entry, len(db.Maps))
+	for i, r := range db.Maps {
+		entries[i] = r.Entry
+	}
+
+	return entries
+}
+
+// sort sorts the records by map name, CRC and workshop ID so the serialized database is stable regardless of the merge order.
+func (db *DB) sort() {
+	sort.Slice(db.Maps, func(i, j int) bool {
+		a, b := db.Maps[i].Entry, db.Maps[j].Entry
+
+		if a.MapName != b.MapName {
+			return a.MapName < b.MapName
+		}
+
+		if a.MapCrc32 != b.MapCrc32 {
+			return a.MapCrc32 < b.MapCrc32
+		}
+
+		return a.WorkshopID < b.WorkshopID
+	})
+
+	db.index = nil
+}
+
+// Load reads a database written by Save.
+func Load(r io.Reader) (*DB, error) {
+	db := new(DB)
+
+	err := json.NewDecoder(r).Decode(db)
+	if err != nil {
+		return nil, errors.Wrap(err, "failed to decode CRC database as JSON")
+	}
+
+	return db, nil
+}
+
+// Save writes the database as indented JSON, sorted by map name, CRC and workshop ID.
+func (db *DB) Save(w io.Writer) error {
+	db.sort()
+
+	if db.Maps == nil {
+		db.Maps = []Record{}
+	}
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "    ")
+
+	err := enc.Encode(db)
+	if err != nil {
+		return errors.Wrap(err, "failed to encode CRC database as JSON")
+	}
+
+	return nil
+}
@@ -0,0 +1,86 @@
+package crcdb_test
+
+import (
+	"bytes"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crcdb"
+)
+
+var (
+	build1 = crcdb.Build{ID: "1.38.0.0", Time: time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC)}
+	build2 = crcdb.Build{ID: "1.38.1.0", Time: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC)}
+	build3 = crcdb.Build{ID: "1.38.2.0", Time: time.Date(2021, 12, 1, 0, 0, 0, 0, time.UTC)}
+
+	nuke     = crc.Entry{MapName: "de_nuke", MapCrc32: 3124679106, WorkshopID: 157233767}
+	cacheOld = crc.Entry{MapName: "de_cache", MapCrc32: 2895852907, WorkshopID: 2497723828}
+	cacheNew = crc.Entry{MapName: "de_cache", MapCrc32: 1111111111, WorkshopID: 2650330155}
+)
+
+func TestAdd(t *testing.T) {
+	var db crcdb.DB
+
+	// builds are added out of order on purpose
+	db.Add(build2, []crc.Entry{nuke, cacheOld, cacheOld})
+	db.Add(build3, []crc.Entry{nuke, cacheNew})
+	db.Add(build1, []crc.Entry{nuke, cacheOld})
+
+	assert.Equal(t, []crc.Entry{cacheNew, cacheOld, nuke}, db.Entries())
+	assert.Equal(t, []crcdb.Record{
+		{Entry: cacheNew, FirstSeen: build3, LastSeen: build3},
+		{Entry: cacheOld, FirstSeen: build1, LastSeen: build2},
+		{Entry: nuke, FirstSeen: build1, LastSeen: build3},
+	}, db.Maps)
+}
+
+func TestMerge(t *testing.T) {
+	var a, b crcdb.DB
+
+	a.Add(build1, []crc.Entry{nuke, cacheOld})
+	b.Add(build3, []crc.Entry{nuke, cacheNew})
+
+	a.Merge(&b)
+
+	assert.Equal(t, []crc.Entry{cacheNew, cacheOld, nuke}, a.Entries())
+	assert.Equal(t, crcdb.Record{Entry: nuke, FirstSeen: build1, LastSeen: build3}, a.Maps[2])
+}
+
+func TestSaveLoad(t *testing.T) {
+	var db1, db2 crcdb.DB
+
+	db1.Add(build1, []crc.Entry{nuke, cacheOld})
+	db1.Add(build2, []crc.Entry{nuke})
+
+	db2.Add(build2, []crc.Entry{nuke})
+	db2.Add(build1, []crc.Entry{cacheOld, nuke})
+
+	var buf1, buf2 bytes.Buffer
+
+	assert.NoError(t, db1.Save(&buf1))
+	assert.NoError(t, db2.Save(&buf2))
+
+	// output doesn't depend on the merge order
+	assert.Equal(t, buf1.String(), buf2.String())
+	assert.Contains(t, buf1.String(), `"map_crc": 3124679106`)
+	assert.Contains(t, buf1.String(), `"first_seen": {`)
+
+	loaded, err := crcdb.Load(&buf1)
+	assert.NoError(t, err)
+	assert.Equal(t, db1.Maps, loaded.Maps)
+
+	// loaded databases can be added to
+	loaded.Add(build3, []crc.Entry{nuke})
+	assert.Equal(t, build3, loaded.Maps[1].LastSeen)
+	assert.Len(t, loaded.Maps, 2)
+}
+
+func TestSaveEmpty(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, new(crcdb.DB).Save(&buf))
+	assert.JSONEq(t, `{"maps": []}`, buf.String())
+}
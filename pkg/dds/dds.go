@@ -0,0 +1,493 @@
+// Package dds implements a decoder for DirectDraw Surface (.dds) images.
+//
+// Supported are DXT1, DXT3 and DXT5 (BC1-3) compressed surfaces as well as uncompressed RGB(A) surfaces
+// described by bit masks (e.g. BGRA8888, BGRX8888, BGR888, BGR565).
+// Images are decoded in the orientation they are stored in (top row first, as per the DDS spec).
+package dds
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"io"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+const magic = "DDS "
+
+func init() {
+	image.RegisterFormat("dds", magic, Decode, DecodeConfig)
+}
+
+const (
+	headerSize      = 124
+	pixelFormatSize = 32
+
+	// maxDimension limits the width and height of images, so crafted headers can't cause huge allocations.
+	maxDimension = 16384
+
+	flagAlphaPixels = 0x1
+	flagFourCC      = 0x4
+	flagRGB         = 0x40
+	flagLuminance   = 0x20000
+)
+
+var (
+	fourCCDXT1 = fourCC("DXT1")
+	fourCCDXT3 = fourCC("DXT3")
+	fourCCDXT5 = fourCC("DXT5")
+)
+
+func fourCC(s string) uint32 {
+	return binary.LittleEndian.Uint32([]byte(s))
+}
+
+// ErrUnsupportedFormat is returned for pixel formats the decoder doesn't handle (e.g. DX10 extended headers).
+var ErrUnsupportedFormat = errors.New("dds: unsupported pixel format")
+
+type pixelFormat struct {
+	Size        uint32
+	Flags       uint32
+	FourCC      uint32
+	RGBBitCount uint32
+	RBitMask    uint32
+	GBitMask    uint32
+	BBitMask    uint32
+	ABitMask    uint32
+}
+
+type header struct {
+	Size              uint32
+	Flags             uint32
+	Height            uint32
+	Width             uint32
+	PitchOrLinearSize uint32
+	Depth             uint32
+	MipMapCount       uint32
+	Reserved1         [11]uint32
+	PixelFormat       pixelFormat
+	Caps              uint32
+	Caps2             uint32
+	Caps3             uint32
+	Caps4             uint32
+	Reserved2         uint32
+}
+
+func readHeader(r io.Reader) (header, error) {
+	var m [4]byte
+
+	_, err := io.ReadFull(r, m[:])
+	if err != nil {
+		return header{}, errors.Wrap(err, "dds: failed to read magic")
+	}
+
+	if string(m[:]) != magic {
+		return header{}, errors.Errorf("dds: invalid magic %q", m)
+	}
+
+	var h header
+
+	err = binary.Read(r, binary.LittleEndian, &h)
+	if err != nil {
+		return header{}, errors.Wrap(err, "dds: failed to read header")
+	}
+
+	if h.Size != headerSize || h.PixelFormat.Size != pixelFormatSize {
+		return header{}, errors.Errorf("dds: invalid header size %d / pixel format size %d", h.Size, h.PixelFormat.Size)
+	}
+
+	if h.Width == 0 || h.Height == 0 {
+		return header{}, errors.Errorf("dds: invalid dimensions %dx%d", h.Width, h.Height)
+	}
+
+	if h.Width > maxDimension || h.Height > maxDimension {
+		return header{}, errors.Errorf("dds: dimensions %dx%d exceed the maximum of %d", h.Width, h.Height, maxDimension)
+	}
+
+	return h, nil
+}
+
+// decoder decodes a single surface of the given dimensions from raw data.
+type decoder struct {
+	// size returns the number of bytes a surface of the given dimensions occupies
+	size   func(w, h int) int
+	decode func(b []byte, w, h int) *image.NRGBA
+}
+
+func blockSize(bytesPerBlock int) func(w, h int) int {
+	return func(w, h int) int {
+		return ((w + 3) / 4) * ((h + 3) / 4) * bytesPerBlock
+	}
+}
+
+func newDecoder(pf pixelFormat) (decoder, error) {
+	if pf.Flags&flagFourCC != 0 {
+		switch pf.FourCC {
+		case fourCCDXT1:
+			return decoder{size: blockSize(8), decode: decodeDXT1}, nil
+		case fourCCDXT3:
+			return decoder{size: blockSize(16), decode: decodeDXT3}, nil
+		case fourCCDXT5:
+			return decoder{size: blockSize(16), decode: decodeDXT5}, nil
+		}
+
+		var cc [4]byte
+		binary.LittleEndian.PutUint32(cc[:], pf.FourCC)
+
+		return decoder{}, errors.Wrapf(ErrUnsupportedFormat, "FourCC %q", cc)
+	}
+
+	if pf.Flags&(flagRGB|flagLuminance) == 0 {
+		return decoder{}, errors.Wrapf(ErrUnsupportedFormat, "flags %#x", pf.Flags)
+	}
+
+	switch pf.RGBBitCount {
+	case 8, 16, 24, 32:
+	default:
+		return decoder{}, errors.Wrapf(ErrUnsupportedFormat, "%d bits per pixel", pf.RGBBitCount)
+	}
+
+	bpp := int(pf.RGBBitCount / 8)
+
+	return decoder{
+		size: func(w, h int) int {
+			return w * h * bpp
+		},
+		decode: func(b []byte, w, h int) *image.NRGBA {
+			return decodeUncompressed(b, w, h, pf)
+		},
+	}, nil
+}
+
+// DecodeConfig returns the dimensions and color model of a DDS image without decoding the pixel data.
+func DecodeConfig(r io.Reader) (image.Config, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	_, err = newDecoder(h.PixelFormat)
+	if err != nil {
+		return image.Config{}, err
+	}
+
+	return image.Config{
+		ColorModel: color.NRGBAModel,
+		Width:      int(h.Width),
+		Height:     int(h.Height),
+	}, nil
+}
+
+// levels returns the number of surfaces according to the header, capped at the length of the mipmap chain.
+func (h header) levels() int {
+	n := h.Width
+	if h.Height > n {
+		n = h.Height
+	}
+
+	levels := int(h.MipMapCount)
+	if levels < 1 {
+		levels = 1
+	}
+
+	if chain := bits.Len32(n); levels > chain {
+		levels = chain
+	}
+
+	return levels
+}
+
+// MipMapCount returns the number of surfaces of a DDS image according to its header, including the main surface.
+// Counts beyond the full mipmap chain (down to 1x1) are capped.
+func MipMapCount(r io.Reader) (int, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return 0, err
+	}
+
+	return h.levels(), nil
+}
+
+// Decode decodes the main (largest) surface of a DDS image.
+func Decode(r io.Reader) (image.Image, error) {
+	imgs, err := decode(r, 1)
+	if err != nil {
+		return nil, err
+	}
+
+	return imgs[0], nil
+}
+
+// DecodeAll decodes the main surface and all mipmaps of a DDS image, largest first.
+func DecodeAll(r io.Reader) ([]image.Image, error) {
+	return decode(r, -1)
+}
+
+func decode(r io.Reader, maxLevels int) ([]image.Image, error) {
+	h, err := readHeader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	dec, err := newDecoder(h.PixelFormat)
+	if err != nil {
+		return nil, err
+	}
+
+	levels := h.levels()
+
+	if maxLevels > 0 && levels > maxLevels {
+		levels = maxLevels
+	}
+
+	var (
+		w    = int(h.Width)
+		hgt  = int(h.Height)
+		imgs = make([]image.Image, 0, levels)
+	)
+
+	for i := 0; i < levels; i++ {
+		b, err := readSurface(r, dec.size(w, hgt))
+		if err != nil {
+			if i > 0 && (err == io.EOF || err == io.ErrUnexpectedEOF) {
+				// some tools write a higher mipmap count than there is data for
+				break
+			}
+
+			return nil, errors.Wrapf(err, "dds: failed to read surface data of mipmap level %d", i)
+		}
+
+		imgs = append(imgs, dec.decode(b, w, hgt))
+
+		if w == 1 && hgt == 1 {
+			break
+		}
+
+		w = halve(w)
+		hgt = halve(hgt)
+	}
+
+	return imgs, nil
+}
+
+// readSurface reads n bytes of surface data like io.ReadFull.
+// The buffer grows with the data that is actually read instead of being allocated up front,
+// so a header declaring large dimensions without the data to back them can't cause a large allocation.
+func readSurface(r io.Reader, n int) ([]byte, error) {
+	var buf bytes.Buffer
+
+	m, err := io.CopyN(&buf, r, int64(n))
+	if err == io.EOF && m > 0 {
+		return nil, io.ErrUnexpectedEOF
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func halve(n int) int {
+	if n <= 1 {
+		return 1
+	}
+
+	return n / 2
+}
+
+// DecodeBytes is a convenience wrapper around Decode.
+func DecodeBytes(b []byte) (image.Image, error) {
+	return Decode(bytes.NewReader(b))
+}
+
+// FlipVertical returns a copy of img mirrored along the horizontal axis.
+func FlipVertical(img image.Image) *image.NRGBA {
+	bounds := img.Bounds()
+	res := image.NewNRGBA(image.Rect(0, 0, bounds.Dx(), bounds.Dy()))
+
+	for y := 0; y < bounds.Dy(); y++ {
+		for x := 0; x < bounds.Dx(); x++ {
+			res.Set(x, bounds.Dy()-1-y, img.At(bounds.Min.X+x, bounds.Min.Y+y))
+		}
+	}
+
+	return res
+}
+
+func rgb565(c uint16) color.NRGBA {
+	r := uint8(c >> 11 & 0x1f)
+	g := uint8(c >> 5 & 0x3f)
+	b := uint8(c & 0x1f)
+
+	return color.NRGBA{
+		R: r<<3 | r>>2,
+		G: g<<2 | g>>4,
+		B: b<<3 | b>>2,
+		A: 0xff,
+	}
+}
+
+func lerp(a, b uint8, num, denom int) uint8 {
+	return uint8((int(a)*(denom-num) + int(b)*num) / denom)
+}
+
+func lerpColor(a, b color.NRGBA, num, denom int) color.NRGBA {
+	return color.NRGBA{
+		R: lerp(a.R, b.R, num, denom),
+		G: lerp(a.G, b.G, num, denom),
+		B: lerp(a.B, b.B, num, denom),
+		A: 0xff,
+	}
+}
+
+// colorBlock decodes the 8 byte color part of a DXT block into 16 pixels.
+// allowTransparent enables DXT1's 3-color + transparent mode.
+func colorBlock(b []byte, allowTransparent bool) [16]color.NRGBA {
+	c0 := binary.LittleEndian.Uint16(b[0:])
+	c1 := binary.LittleEndian.Uint16(b[2:])
+	indices := binary.LittleEndian.Uint32(b[4:])
+
+	var palette [4]color.NRGBA
+	palette[0] = rgb565(c0)
+	palette[1] = rgb565(c1)
+
+	if c0 > c1 || !allowTransparent {
+		palette[2] = lerpColor(palette[0], palette[1], 1, 3)
+		palette[3] = lerpColor(palette[0], palette[1], 2, 3)
+	} else {
+		palette[2] = lerpColor(palette[0], palette[1], 1, 2)
+		palette[3] = color.NRGBA{}
+	}
+
+	var res [16]color.NRGBA
+	for i := range res {
+		res[i] = palette[indices>>(2*i)&0x3]
+	}
+
+	return res
+}
+
+func decodeBlocks(b []byte, w, h, bytesPerBlock int, block func(b []byte) [16]color.NRGBA) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	blocksX := (w + 3) / 4
+
+	for by := 0; by < (h+3)/4; by++ {
+		for bx := 0; bx < blocksX; bx++ {
+			off := (by*blocksX + bx) * bytesPerBlock
+			px := block(b[off : off+bytesPerBlock])
+
+			for i, c := range px {
+				x, y := bx*4+i%4, by*4+i/4
+				if x < w && y < h {
+					img.SetNRGBA(x, y, c)
+				}
+			}
+		}
+	}
+
+	return img
+}
+
+func decodeDXT1(b []byte, w, h int) *image.NRGBA {
+	return decodeBlocks(b, w, h, 8, func(b []byte) [16]color.NRGBA {
+		return colorBlock(b, true)
+	})
+}
+
+func decodeDXT3(b []byte, w, h int) *image.NRGBA {
+	return decodeBlocks(b, w, h, 16, func(b []byte) [16]color.NRGBA {
+		px := colorBlock(b[8:], false)
+		alpha := binary.LittleEndian.Uint64(b)
+
+		for i := range px {
+			a := uint8(alpha >> (4 * i) & 0xf)
+			px[i].A = a<<4 | a
+		}
+
+		return px
+	})
+}
+
+func decodeDXT5(b []byte, w, h int) *image.NRGBA {
+	return decodeBlocks(b, w, h, 16, func(b []byte) [16]color.NRGBA {
+		px := colorBlock(b[8:], false)
+
+		var palette [8]uint8
+		palette[0], palette[1] = b[0], b[1]
+
+		if palette[0] > palette[1] {
+			for i := 1; i < 7; i++ {
+				palette[i+1] = lerp(palette[0], palette[1], i, 7)
+			}
+		} else {
+			for i := 1; i < 5; i++ {
+				palette[i+1] = lerp(palette[0], palette[1], i, 5)
+			}
+
+			palette[6] = 0
+			palette[7] = 0xff
+		}
+
+		var indices uint64
+		for i := 7; i >= 2; i-- {
+			indices = indices<<8 | uint64(b[i])
+		}
+
+		for i := range px {
+			px[i].A = palette[indices>>(3*i)&0x7]
+		}
+
+		return px
+	})
+}
+
+// channel extracts the bits of mask from px and scales them to 8 bits.
+func channel(px, mask uint32) uint8 {
+	if mask == 0 {
+		return 0
+	}
+
+	shift := bits.TrailingZeros32(mask)
+	n := bits.OnesCount32(mask)
+	v := (px & mask) >> shift
+	maxV := uint32(1)<<n - 1
+
+	return uint8(v * 0xff / maxV)
+}
+
+func decodeUncompressed(b []byte, w, h int, pf pixelFormat) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	bpp := int(pf.RGBBitCount / 8)
+	hasAlpha := pf.Flags&flagAlphaPixels != 0 && pf.ABitMask != 0
+	luminance := pf.Flags&flagLuminance != 0
+
+	for i := 0; i < w*h; i++ {
+		var px uint32
+		for j := bpp - 1; j >= 0; j-- {
+			px = px<<8 | uint32(b[i*bpp+j])
+		}
+
+		c := color.NRGBA{A: 0xff}
+
+		if luminance {
+			c.R = channel(px, pf.RBitMask)
+			c.G, c.B = c.R, c.R
+		} else {
+			c.R = channel(px, pf.RBitMask)
+			c.G = channel(px, pf.GBitMask)
+			c.B = channel(px, pf.BBitMask)
+		}
+
+		if hasAlpha {
+			c.A = channel(px, pf.ABitMask)
+		}
+
+		img.SetNRGBA(i%w, i/w, c)
+	}
+
+	return img
+}
@@ -0,0 +1,191 @@
+package dds_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"image"
+	"image/color"
+	"runtime"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/dds"
+)
+
+type pixelFormat struct {
+	Size, Flags, FourCC, RGBBitCount, RBitMask, GBitMask, BBitMask, ABitMask uint32
+}
+
+func ddsFile(t *testing.T, w, h, mipmaps int, pf pixelFormat, data []byte) []byte {
+	t.Helper()
+
+	var buf bytes.Buffer
+	buf.WriteString("DDS ")
+
+	pf.Size = 32
+
+	hdr := struct {
+		Size, Flags, Height, Width, PitchOrLinearSize, Depth, MipMapCount uint32
+		Reserved1                                                         [11]uint32
+		PixelFormat                                                       pixelFormat
+		Caps, Caps2, Caps3, Caps4, Reserved2                              uint32
+	}{
+		Size:        124,
+		Height:      uint32(h),
+		Width:       uint32(w),
+		MipMapCount: uint32(mipmaps),
+		PixelFormat: pf,
+	}
+
+	assert.NoError(t, binary.Write(&buf, binary.LittleEndian, hdr))
+	buf.Write(data)
+
+	return buf.Bytes()
+}
+
+func fourCC(s string) uint32 {
+	return binary.LittleEndian.Uint32([]byte(s))
+}
+
+var (
+	red  = color.NRGBA{R: 0xff, A: 0xff}
+	blue = color.NRGBA{B: 0xff, A: 0xff}
+)
+
+func TestDecodeDXT1(t *testing.T) {
+	// c0 = red, c1 = blue; top row uses index 0, all other rows index 1
+	block := []byte{0x00, 0xf8, 0x1f, 0x00, 0x00, 0x55, 0x55, 0x55}
+
+	img, err := dds.DecodeBytes(ddsFile(t, 4, 4, 1, pixelFormat{Flags: 0x4, FourCC: fourCC("DXT1")}, block))
+	assert.NoError(t, err)
+
+	assert.Equal(t, image.Rect(0, 0, 4, 4), img.Bounds())
+	assert.Equal(t, red, img.At(0, 0))
+	assert.Equal(t, blue, img.At(3, 3))
+
+	flipped := dds.FlipVertical(img)
+	assert.Equal(t, blue, flipped.At(0, 0))
+	assert.Equal(t, red, flipped.At(3, 3))
+}
+
+func TestDecodeDXT1Transparent(t *testing.T) {
+	// c0 <= c1 enables 3-color mode, index 3 is transparent
+	block := []byte{0x1f, 0x00, 0x00, 0xf8, 0xff, 0xff, 0xff, 0xff}
+
+	img, err := dds.DecodeBytes(ddsFile(t, 4, 4, 1, pixelFormat{Flags: 0x4, FourCC: fourCC("DXT1")}, block))
+	assert.NoError(t, err)
+
+	_, _, _, a := img.At(1, 1).RGBA()
+	assert.Zero(t, a)
+}
+
+func TestDecodeDXT3(t *testing.T) {
+	block := append([]byte{0x0f, 0, 0, 0, 0, 0, 0, 0xf0}, 0x00, 0xf8, 0x1f, 0x00, 0, 0, 0, 0)
+
+	img, err := dds.DecodeBytes(ddsFile(t, 4, 4, 1, pixelFormat{Flags: 0x4, FourCC: fourCC("DXT3")}, block))
+	assert.NoError(t, err)
+
+	assert.Equal(t, red, img.At(0, 0))
+	assert.Equal(t, color.NRGBA{R: 0xff}, img.At(1, 0))
+	assert.Equal(t, red, img.At(3, 3))
+}
+
+func TestDecodeDXT5(t *testing.T) {
+	// a0 = 255, a1 = 0; first pixel uses index 0 (255), second index 1 (0)
+	block := append([]byte{0xff, 0x00, 0x08, 0, 0, 0, 0, 0}, 0x00, 0xf8, 0x1f, 0x00, 0, 0, 0, 0)
+
+	img, err := dds.DecodeBytes(ddsFile(t, 4, 4, 1, pixelFormat{Flags: 0x4, FourCC: fourCC("DXT5")}, block))
+	assert.NoError(t, err)
+
+	assert.Equal(t, red, img.At(0, 0))
+	assert.Equal(t, color.NRGBA{R: 0xff}, img.At(1, 0))
+}
+
+func TestDecodeBGRAWithMipmaps(t *testing.T) {
+	pf := pixelFormat{
+		Flags:       0x41,
+		RGBBitCount: 32,
+		RBitMask:    0x00ff0000,
+		GBitMask:    0x0000ff00,
+		BBitMask:    0x000000ff,
+		ABitMask:    0xff000000,
+	}
+
+	// 2x1 main surface (red, blue) + 1x1 mipmap (blue, half transparent)
+	data := []byte{
+		0x00, 0x00, 0xff, 0xff, 0xff, 0x00, 0x00, 0xff,
+		0xff, 0x00, 0x00, 0x80,
+	}
+
+	b := ddsFile(t, 2, 1, 2, pf, data)
+
+	imgs, err := dds.DecodeAll(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Len(t, imgs, 2)
+
+	n, err := dds.MipMapCount(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, 2, n)
+
+	assert.Equal(t, red, imgs[0].At(0, 0))
+	assert.Equal(t, blue, imgs[0].At(1, 0))
+	assert.Equal(t, image.Rect(0, 0, 1, 1), imgs[1].Bounds())
+	assert.Equal(t, color.NRGBA{B: 0xff, A: 0x80}, imgs[1].At(0, 0))
+}
+
+func TestImageDecodeRegistered(t *testing.T) {
+	block := []byte{0x00, 0xf8, 0x1f, 0x00, 0x00, 0x55, 0x55, 0x55}
+
+	_, format, err := image.Decode(bytes.NewReader(ddsFile(t, 4, 4, 1, pixelFormat{Flags: 0x4, FourCC: fourCC("DXT1")}, block)))
+	assert.NoError(t, err)
+	assert.Equal(t, "dds", format)
+}
+
+func TestDecodeUnsupported(t *testing.T) {
+	_, err := dds.DecodeBytes(ddsFile(t, 4, 4, 1, pixelFormat{Flags: 0x4, FourCC: fourCC("DX10")}, nil))
+	assert.ErrorIs(t, err, dds.ErrUnsupportedFormat)
+
+	_, err = dds.DecodeBytes([]byte("PNG nope"))
+	assert.Error(t, err)
+}
+
+func TestDecodeMaliciousHeader(t *testing.T) {
+	dxt1 := pixelFormat{Flags: 0x4, FourCC: fourCC("DXT1")}
+
+	_, err := dds.DecodeBytes(ddsFile(t, 1<<31, 1<<31, 1, dxt1, nil))
+	assert.EqualError(t, err, "dds: dimensions 2147483648x2147483648 exceed the maximum of 16384")
+
+	_, err = dds.DecodeConfig(bytes.NewReader(ddsFile(t, 4, 16385, 1, dxt1, nil)))
+	assert.Error(t, err)
+
+	// a 4x4 image has 3 surfaces at most (4x4, 2x2, 1x1)
+	block := []byte{0x00, 0xf8, 0x1f, 0x00, 0x00, 0x55, 0x55, 0x55}
+	b := ddsFile(t, 4, 4, 0xffffffff, dxt1, bytes.Repeat(block, 3))
+
+	n, err := dds.MipMapCount(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, 3, n)
+
+	imgs, err := dds.DecodeAll(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Len(t, imgs, 3)
+}
+
+func TestDecodeTruncatedLargeImage(t *testing.T) {
+	dxt1 := pixelFormat{Flags: 0x4, FourCC: fourCC("DXT1")}
+
+	// 16384x16384 DXT1 needs 128 MiB of surface data and a 1 GiB image, the file only has 1 KiB
+	b := ddsFile(t, 16384, 16384, 1, dxt1, make([]byte, 1024))
+
+	var before, after runtime.MemStats
+
+	runtime.ReadMemStats(&before)
+
+	_, err := dds.Decode(bytes.NewReader(b))
+	assert.EqualError(t, err, "dds: failed to read surface data of mipmap level 0: unexpected EOF")
+
+	runtime.ReadMemStats(&after)
+
+	assert.Less(t, after.TotalAlloc-before.TotalAlloc, uint64(1<<20), "allocations should be bounded by the data, not the header")
+}
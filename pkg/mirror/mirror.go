@@ -0,0 +1,279 @@
+// Package mirror downloads the radar assets of every map version in a CRC table into a local directory tree.
+//
+// Assets are stored as <dir>/<map>/<crc>/<file>, the same layout the server package uses for its cache,
+// so a mirror can be served directly with `csgo-centrifuge serve --cache-dir <dir>`.
+package mirror
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"path/filepath"
+	"sort"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+// DefaultWorkers is the default number of workshop items processed concurrently.
+const DefaultWorkers = 4
+
+// Status is the outcome of mirroring a single map version.
+type Status string
+
+const (
+	StatusOK      Status = "ok"
+	StatusSkipped Status = "skipped"
+	StatusFailed  Status = "failed"
+)
+
+// Result describes the outcome of mirroring a single map version.
+type Result struct {
+	crc.Entry
+	Status Status `json:"status"`
+	// Dir is the directory of the assets, relative to the mirror dir.
+	Dir string `json:"dir"`
+	// Files are the extracted asset files, maps without radar or nav mesh have fewer of them.
+	Files []string `json:"files,omitempty"`
+	Error string   `json:"error,omitempty"`
+}
+
+// Manifest summarizes a mirror run.
+type Manifest struct {
+	Succeeded int      `json:"succeeded"`
+	Skipped   int      `json:"skipped"`
+	Failed    int      `json:"failed"`
+	Results   []Result `json:"results"`
+}
+
+// Mirror downloads and extracts radar assets for many map versions.
+// The zero value is usable.
+type Mirror struct {
+	// Client is used to download workshop items, defaults to steamapi.DefaultClient.
+	Client *steamapi.Client
+	// Workers is the maximum number of workshop items processed concurrently, defaults to DefaultWorkers.
+	Workers int
+	// Logger receives a line per processed map version if set.
+	Logger *log.Logger
+}
+
+func (m *Mirror) client() *steamapi.Client {
+	if m.Client == nil {
+		return steamapi.DefaultClient
+	}
+
+	return m.Client
+}
+
+func (m *Mirror) workers() int {
+	if m.Workers <= 0 {
+		return DefaultWorkers
+	}
+
+	return m.Workers
+}
+
+func (m *Mirror) logf(format string, args ...interface{}) {
+	if m.Logger != nil {
+		m.Logger.Printf(format, args...)
+	}
+}
+
+// Run mirrors the assets of all entries in table to dir.
+// Entries whose assets are already complete are skipped, duplicate entries are only processed once.
+// Each workshop item is downloaded once and verified against all entries referencing it, since old map versions often share an item
+// that only contains the latest one. CRC mismatches are cached like in the server package, so they aren't downloaded again either.
+// Failures of individual entries don't abort the run, they are recorded in the manifest.
+func (m *Mirror) Run(ctx context.Context, table []crc.Entry, dir string) *Manifest {
+	var (
+		entries = dedupe(table)
+		items   = byWorkshopID(entries)
+		results = make([]Result, len(entries))
+		jobs    = make(chan []int)
+		wg      sync.WaitGroup
+	)
+
+	for i := 0; i < m.workers(); i++ {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			for item := range jobs {
+				m.mirrorItem(ctx, entries, item, dir, results)
+
+				for _, j := range item {
+					m.logf("%s %s/%d (workshop item %d) %s", results[j].Status, entries[j].MapName, entries[j].MapCrc32, entries[j].WorkshopID, results[j].Error)
+				}
+			}
+		}()
+	}
+
+	for _, item := range items {
+		jobs <- item
+	}
+
+	close(jobs)
+	wg.Wait()
+
+	manifest := &Manifest{Results: results}
+
+	for _, r := range results {
+		switch r.Status {
+		case StatusOK:
+			manifest.Succeeded++
+		case StatusSkipped:
+			manifest.Skipped++
+		case StatusFailed:
+			manifest.Failed++
+		}
+	}
+
+	return manifest
+}
+
+func dedupe(table []crc.Entry) []crc.Entry {
+	var (
+		res  = make([]crc.Entry, 0, len(table))
+		seen = make(map[crc.Entry]bool, len(table))
+	)
+
+	for _, e := range table {
+		if !seen[e] {
+			seen[e] = true
+			res = append(res, e)
+		}
+	}
+
+	return res
+}
+
+// byWorkshopID groups the indices of entries by workshop item, in the order of their first entry.
+func byWorkshopID(entries []crc.Entry) [][]int {
+	var (
+		res   [][]int
+		index = make(map[uint32]int)
+	)
+
+	for i, e := range entries {
+		j, ok := index[e.WorkshopID]
+		if !ok {
+			j = len(res)
+			index[e.WorkshopID] = j
+			res = append(res, nil)
+		}
+
+		res[j] = append(res[j], i)
+	}
+
+	return res
+}
+
+// mirrorItem mirrors the entries at the indices in item, which all reference the same workshop item, and stores their results.
+func (m *Mirror) mirrorItem(ctx context.Context, entries []crc.Entry, item []int, dir string, results []Result) {
+	var pending []int
+
+	for _, i := range item {
+		rel := filepath.Join(entries[i].MapName, fmt.Sprint(entries[i].MapCrc32))
+		results[i] = Result{Entry: entries[i], Dir: filepath.ToSlash(rel)}
+
+		if bsputil.AssetsComplete(filepath.Join(dir, rel)) {
+			results[i].Status = StatusSkipped
+			listFiles(&results[i], dir)
+
+			continue
+		}
+
+		cached, ok := bsputil.CachedCRCMismatch(filepath.Join(dir, rel), entries[i])
+		if ok {
+			results[i].Status = StatusFailed
+			results[i].Error = errors.Wrapf(cached, "cached result for workshop item %d", entries[i].WorkshopID).Error()
+
+			continue
+		}
+
+		pending = append(pending, i)
+	}
+
+	if len(pending) == 0 {
+		return
+	}
+
+	fail := func(err error) {
+		for _, i := range pending {
+			results[i].Status = StatusFailed
+			results[i].Error = err.Error()
+		}
+	}
+
+	err := ctx.Err()
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	bspF, err := bsputil.GetWorkshopBSP(ctx, m.client(), int(entries[pending[0]].WorkshopID))
+	if err != nil {
+		fail(err)
+		return
+	}
+
+	for _, i := range pending {
+		assetDir := filepath.Join(dir, filepath.FromSlash(results[i].Dir))
+
+		err = bsputil.ExtractAssetsAtomic(bspF, entries[i], assetDir)
+
+		var mismatchErr *bsputil.CRCMismatchError
+		if errors.As(err, &mismatchErr) {
+			cacheErr := bsputil.CacheCRCMismatch(assetDir, mismatchErr)
+			if cacheErr != nil {
+				m.logf("failed to cache CRC mismatch: %v", cacheErr)
+			}
+		}
+
+		if err != nil {
+			results[i].Status = StatusFailed
+			results[i].Error = err.Error()
+
+			continue
+		}
+
+		results[i].Status = StatusOK
+		listFiles(&results[i], dir)
+	}
+}
+
+// listFiles sets the files of a successful result, it fails the result if they can't be listed.
+func listFiles(res *Result, dir string) {
+	var err error
+
+	res.Files, err = assetFiles(filepath.Join(dir, filepath.FromSlash(res.Dir)))
+	if err != nil {
+		res.Status = StatusFailed
+		res.Error = err.Error()
+	}
+}
+
+func assetFiles(dir string) ([]string, error) {
+	infos, err := ioutil.ReadDir(dir)
+	if err != nil {
+		return nil, errors.Wrapf(err, "failed to list assets in %q", dir)
+	}
+
+	var files []string
+
+	for _, info := range infos {
+		if !info.IsDir() && info.Name() != bsputil.AssetsCompleteMarker {
+			files = append(files, info.Name())
+		}
+	}
+
+	sort.Strings(files)
+
+	return files, nil
+}
@@ -0,0 +1,142 @@
+package mirror_test
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/mirror"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+const trainRadarInfo = `"de_train"
+{
+	"pos_x"		"-2477"
+	"pos_y"		"2392"
+	"scale"		"4.7"
+}
+`
+
+func mapBSP(mapName, radarInfo string) []byte {
+	return fixture.MapBSP(map[string][]byte{
+		"resource/overviews/" + mapName + ".txt":       []byte(radarInfo),
+		"resource/overviews/" + mapName + "_radar.dds": fixture.DDS(),
+		"maps/" + mapName + ".nav":                     []byte("nav"),
+	})
+}
+
+func workshopItem(mapName string, bspB []byte) []byte {
+	return fixture.Zip(map[string][]byte{mapName + ".bsp": bspB})
+}
+
+var (
+	// two versions of de_train with different map CRCs
+	trainV1 = mapBSP("de_train", trainRadarInfo)
+	trainV2 = mapBSP("de_train", trainRadarInfo+"\n")
+
+	table = []crc.Entry{
+		{MapName: "de_train", MapCrc32: fixture.MapCRC(trainV1), WorkshopID: 1},
+		{MapName: "de_train", MapCrc32: fixture.MapCRC(trainV1), WorkshopID: 1},
+		{MapName: "de_train", MapCrc32: fixture.MapCRC(trainV2), WorkshopID: 2},
+		{MapName: "de_removed", MapCrc32: 5678, WorkshopID: 3},
+	}
+)
+
+func TestRun(t *testing.T) {
+	api := fixture.NewSteamAPI(map[int][]byte{
+		1: workshopItem("de_train", trainV1),
+		2: workshopItem("de_train", trainV2),
+	})
+	defer api.Close()
+
+	dir := t.TempDir()
+	m := &mirror.Mirror{Client: &steamapi.Client{BaseURL: api.URL}, Workers: 2}
+
+	manifest := m.Run(context.Background(), table, dir)
+	assert.Equal(t, 2, manifest.Succeeded)
+	assert.Equal(t, 0, manifest.Skipped)
+	assert.Equal(t, 1, manifest.Failed)
+	assert.Len(t, manifest.Results, 3)
+
+	ok := manifest.Results[0]
+	assert.Equal(t, mirror.StatusOK, ok.Status)
+	assert.Equal(t, table[0], ok.Entry)
+	assert.Equal(t, fmt.Sprintf("de_train/%d", table[0].MapCrc32), ok.Dir)
+	assert.Equal(t, []string{"info.json", "info.txt", "nav.nav", "radar.dds", "radar.png"}, ok.Files)
+
+	info, err := ioutil.ReadFile(filepath.Join(dir, ok.Dir, "info.txt"))
+	assert.NoError(t, err)
+	assert.Equal(t, trainRadarInfo, string(info))
+
+	failed := manifest.Results[2]
+	assert.Equal(t, mirror.StatusFailed, failed.Status)
+	assert.Equal(t, table[3], failed.Entry)
+	assert.NotEmpty(t, failed.Error)
+	assert.NoDirExists(t, filepath.Join(dir, "de_removed", "5678"))
+
+	// second run only retries the failed entry
+	manifest = m.Run(context.Background(), table, dir)
+	assert.Equal(t, 0, manifest.Succeeded)
+	assert.Equal(t, 2, manifest.Skipped)
+	assert.Equal(t, 1, manifest.Failed)
+	assert.Equal(t, []string{"info.json", "info.txt", "nav.nav", "radar.dds", "radar.png"}, manifest.Results[0].Files)
+	assert.Equal(t, 1, api.Downloads(1))
+	assert.Equal(t, 1, api.Downloads(2))
+}
+
+func TestRunCRCMismatch(t *testing.T) {
+	// the workshop item was updated since the table was extracted
+	api := fixture.NewSteamAPI(map[int][]byte{1: workshopItem("de_train", trainV2)})
+	defer api.Close()
+
+	dir := t.TempDir()
+	m := &mirror.Mirror{Client: &steamapi.Client{BaseURL: api.URL}}
+
+	manifest := m.Run(context.Background(), table[:1], dir)
+	assert.Equal(t, 1, manifest.Failed)
+	assert.Contains(t, manifest.Results[0].Error, fmt.Sprintf("BSP has map CRC %d, expected %d", table[2].MapCrc32, table[0].MapCrc32))
+	assert.NoDirExists(t, filepath.Join(dir, manifest.Results[0].Dir))
+}
+
+func TestRunSharedWorkshopItem(t *testing.T) {
+	// both versions reference the same workshop item, which only contains the latest one
+	api := fixture.NewSteamAPI(map[int][]byte{1: workshopItem("de_train", trainV2)})
+	defer api.Close()
+
+	versions := []crc.Entry{
+		{MapName: "de_train", MapCrc32: table[0].MapCrc32, WorkshopID: 1},
+		{MapName: "de_train", MapCrc32: table[2].MapCrc32, WorkshopID: 1},
+	}
+
+	dir := t.TempDir()
+	m := &mirror.Mirror{Client: &steamapi.Client{BaseURL: api.URL}}
+
+	manifest := m.Run(context.Background(), versions, dir)
+	assert.Equal(t, 1, manifest.Succeeded)
+	assert.Equal(t, 1, manifest.Failed)
+	assert.Equal(t, mirror.StatusFailed, manifest.Results[0].Status)
+	assert.Contains(t, manifest.Results[0].Error, fmt.Sprintf("BSP has map CRC %d, expected %d", table[2].MapCrc32, table[0].MapCrc32))
+	assert.Equal(t, mirror.StatusOK, manifest.Results[1].Status)
+	assert.Equal(t, 1, api.Downloads(1), "the workshop item should be downloaded once for all of its CRCs")
+
+	// the mismatch is cached, so the item isn't downloaded again
+	manifest = m.Run(context.Background(), versions, dir)
+	assert.Equal(t, 1, manifest.Skipped)
+	assert.Equal(t, 1, manifest.Failed)
+	assert.Contains(t, manifest.Results[0].Error, "cached result for workshop item 1")
+	assert.Equal(t, 1, api.Downloads(1))
+}
+
+func TestRunCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	manifest := new(mirror.Mirror).Run(ctx, table, t.TempDir())
+	assert.Equal(t, 3, manifest.Failed)
+}
@@ -0,0 +1,421 @@
+// Package nav implements a parser for Source engine navigation mesh (.nav) files.
+//
+// See https://developer.valvesoftware.com/wiki/NAV for a description of the format.
+// Versions up to 16 (CS:GO) are supported.
+package nav
+
+import (
+	"bufio"
+	"encoding/binary"
+	"io"
+	"math"
+
+	"github.com/pkg/errors"
+)
+
+// Magic is the magic number every .nav file starts with.
+const Magic = 0xFEEDFACE
+
+// MaxVersion is the highest nav mesh version the parser understands.
+const MaxVersion = 16
+
+type Vector struct {
+	X float32 `json:"x"`
+	Y float32 `json:"y"`
+	Z float32 `json:"z"`
+}
+
+// Connections are the IDs of adjacent areas per direction.
+type Connections struct {
+	North []uint32 `json:"north"`
+	East  []uint32 `json:"east"`
+	South []uint32 `json:"south"`
+	West  []uint32 `json:"west"`
+}
+
+func (c *Connections) direction(dir int) *[]uint32 {
+	return [...]*[]uint32{&c.North, &c.East, &c.South, &c.West}[dir]
+}
+
+type HidingSpot struct {
+	ID       uint32 `json:"id"`
+	Position Vector `json:"position"`
+	Flags    uint8  `json:"flags"`
+}
+
+type ApproachSpot struct {
+	HereID        uint32 `json:"here_id"`
+	PrevID        uint32 `json:"prev_id"`
+	PrevToHereHow uint8  `json:"prev_to_here_how"`
+	NextID        uint32 `json:"next_id"`
+	HereToNextHow uint8  `json:"here_to_next_how"`
+}
+
+type EncounterSpot struct {
+	HidingSpotID uint32 `json:"hiding_spot_id"`
+	// ParametricDistance is the position along the path, from 0 (start) to 1 (end).
+	ParametricDistance float32 `json:"parametric_distance"`
+}
+
+type EncounterPath struct {
+	FromAreaID    uint32          `json:"from_area_id"`
+	FromDirection uint8           `json:"from_direction"`
+	ToAreaID      uint32          `json:"to_area_id"`
+	ToDirection   uint8           `json:"to_direction"`
+	Spots         []EncounterSpot `json:"spots"`
+}
+
+type VisibleArea struct {
+	ID         uint32 `json:"id"`
+	Attributes uint8  `json:"attributes"`
+}
+
+type Area struct {
+	ID                    uint32          `json:"id"`
+	Attributes            uint32          `json:"attributes"`
+	NorthWest             Vector          `json:"north_west"`
+	SouthEast             Vector          `json:"south_east"`
+	NorthEastZ            float32         `json:"north_east_z"`
+	SouthWestZ            float32         `json:"south_west_z"`
+	Connections           Connections     `json:"connections"`
+	HidingSpots           []HidingSpot    `json:"hiding_spots"`
+	ApproachSpots         []ApproachSpot  `json:"approach_spots"`
+	EncounterPaths        []EncounterPath `json:"encounter_paths"`
+	PlaceID               uint16          `json:"place_id"`
+	Place                 string          `json:"place,omitempty"`
+	LadderIDsUp           []uint32        `json:"ladder_ids_up"`
+	LadderIDsDown         []uint32        `json:"ladder_ids_down"`
+	EarliestOccupyTimes   [2]float32      `json:"earliest_occupy_times"`
+	LightIntensity        [4]float32      `json:"light_intensity"`
+	VisibleAreas          []VisibleArea   `json:"visible_areas"`
+	InheritVisibilityFrom uint32          `json:"inherit_visibility_from"`
+}
+
+type Ladder struct {
+	ID               uint32  `json:"id"`
+	Width            float32 `json:"width"`
+	Top              Vector  `json:"top"`
+	Bottom           Vector  `json:"bottom"`
+	Length           float32 `json:"length"`
+	Direction        uint32  `json:"direction"`
+	TopForwardAreaID uint32  `json:"top_forward_area_id"`
+	TopLeftAreaID    uint32  `json:"top_left_area_id"`
+	TopRightAreaID   uint32  `json:"top_right_area_id"`
+	TopBehindAreaID  uint32  `json:"top_behind_area_id"`
+	BottomAreaID     uint32  `json:"bottom_area_id"`
+	Dangling         bool    `json:"dangling,omitempty"`
+}
+
+// Mesh is a parsed navigation mesh.
+type Mesh struct {
+	Version         uint32   `json:"version"`
+	SubVersion      uint32   `json:"sub_version"`
+	BSPSize         uint32   `json:"bsp_size"`
+	Analyzed        bool     `json:"analyzed"`
+	Places          []string `json:"places"`
+	HasUnnamedAreas bool     `json:"has_unnamed_areas"`
+	Areas           []Area   `json:"areas"`
+	Ladders         []Ladder `json:"ladders"`
+}
+
+// AreaByID returns the area with the given ID or nil if there is none.
+func (m *Mesh) AreaByID(id uint32) *Area {
+	for i := range m.Areas {
+		if m.Areas[i].ID == id {
+			return &m.Areas[i]
+		}
+	}
+
+	return nil
+}
+
+// reader wraps an io.Reader and remembers the first error, so reads can be chained without checking each one.
+type reader struct {
+	r   io.Reader
+	buf [8]byte
+	err error
+}
+
+func (r *reader) read(n int) []byte {
+	if r.err != nil {
+		return r.buf[:n]
+	}
+
+	_, r.err = io.ReadFull(r.r, r.buf[:n])
+
+	return r.buf[:n]
+}
+
+func (r *reader) u8() uint8 {
+	return r.read(1)[0]
+}
+
+func (r *reader) u16() uint16 {
+	return binary.LittleEndian.Uint16(r.read(2))
+}
+
+func (r *reader) u32() uint32 {
+	return binary.LittleEndian.Uint32(r.read(4))
+}
+
+func (r *reader) f32() float32 {
+	return math.Float32frombits(r.u32())
+}
+
+func (r *reader) vector() Vector {
+	return Vector{X: r.f32(), Y: r.f32(), Z: r.f32()}
+}
+
+func (r *reader) bytes(n int) []byte {
+	if r.err != nil {
+		return nil
+	}
+
+	b := make([]byte, n)
+	_, r.err = io.ReadFull(r.r, b)
+
+	return b
+}
+
+func (r *reader) ids() []uint32 {
+	n := r.u32()
+	if r.err != nil {
+		return nil
+	}
+
+	// don't trust the count for preallocation, corrupt files would allocate huge slices
+	ids := make([]uint32, 0, n%1024)
+	for i := uint32(0); i < n && r.err == nil; i++ {
+		ids = append(ids, r.u32())
+	}
+
+	return ids
+}
+
+// Parse reads a navigation mesh.
+func Parse(r io.Reader) (*Mesh, error) {
+	rd := &reader{r: bufio.NewReader(r)}
+
+	magic := rd.u32()
+	if rd.err != nil {
+		return nil, errors.Wrap(rd.err, "failed to read nav mesh header")
+	}
+
+	if magic != Magic {
+		return nil, errors.Errorf("invalid nav mesh magic %#x", magic)
+	}
+
+	m := &Mesh{Version: rd.u32()}
+
+	if m.Version > MaxVersion {
+		return nil, errors.Errorf("unsupported nav mesh version %d", m.Version)
+	}
+
+	if m.Version >= 10 {
+		m.SubVersion = rd.u32()
+	}
+
+	if m.Version >= 4 {
+		m.BSPSize = rd.u32()
+	}
+
+	if m.Version >= 14 {
+		m.Analyzed = rd.u8() != 0
+	}
+
+	if m.Version >= 5 {
+		n := int(rd.u16())
+		for i := 0; i < n && rd.err == nil; i++ {
+			name := rd.bytes(int(rd.u16()))
+			m.Places = append(m.Places, cString(name))
+		}
+
+		if m.Version > 11 {
+			m.HasUnnamedAreas = rd.u8() != 0
+		}
+	}
+
+	nAreas := rd.u32()
+	if rd.err != nil {
+		return nil, errors.Wrap(rd.err, "failed to read nav mesh header")
+	}
+
+	for i := uint32(0); i < nAreas; i++ {
+		area := m.readArea(rd)
+		if rd.err != nil {
+			return nil, errors.Wrapf(rd.err, "failed to read nav area %d of %d", i, nAreas)
+		}
+
+		m.Areas = append(m.Areas, area)
+	}
+
+	if m.Version >= 6 {
+		nLadders := rd.u32()
+
+		for i := uint32(0); i < nLadders && rd.err == nil; i++ {
+			m.Ladders = append(m.Ladders, m.readLadder(rd))
+		}
+
+		if rd.err != nil {
+			return nil, errors.Wrap(rd.err, "failed to read nav ladders")
+		}
+	}
+
+	return m, nil
+}
+
+func cString(b []byte) string {
+	for i, c := range b {
+		if c == 0 {
+			return string(b[:i])
+		}
+	}
+
+	return string(b)
+}
+
+func (m *Mesh) readArea(rd *reader) Area {
+	a := Area{ID: rd.u32()}
+
+	switch {
+	case m.Version <= 8:
+		a.Attributes = uint32(rd.u8())
+	case m.Version <= 12:
+		a.Attributes = uint32(rd.u16())
+	default:
+		a.Attributes = rd.u32()
+	}
+
+	a.NorthWest = rd.vector()
+	a.SouthEast = rd.vector()
+	a.NorthEastZ = rd.f32()
+	a.SouthWestZ = rd.f32()
+
+	for dir := 0; dir < 4; dir++ {
+		*a.Connections.direction(dir) = rd.ids()
+	}
+
+	nSpots := int(rd.u8())
+	for i := 0; i < nSpots && rd.err == nil; i++ {
+		var spot HidingSpot
+
+		if m.Version >= 2 {
+			spot.ID = rd.u32()
+		}
+
+		spot.Position = rd.vector()
+
+		if m.Version >= 2 {
+			spot.Flags = rd.u8()
+		}
+
+		a.HidingSpots = append(a.HidingSpots, spot)
+	}
+
+	// approach spots were moved to the game specific (CS) area data in version 15
+	if m.Version < 15 {
+		a.ApproachSpots = readApproachSpots(rd)
+	}
+
+	nPaths := rd.u32()
+	for i := uint32(0); i < nPaths && rd.err == nil; i++ {
+		path := EncounterPath{
+			FromAreaID:    rd.u32(),
+			FromDirection: rd.u8(),
+			ToAreaID:      rd.u32(),
+			ToDirection:   rd.u8(),
+		}
+
+		n := int(rd.u8())
+		for j := 0; j < n && rd.err == nil; j++ {
+			path.Spots = append(path.Spots, EncounterSpot{
+				HidingSpotID:       rd.u32(),
+				ParametricDistance: float32(rd.u8()) / 255,
+			})
+		}
+
+		a.EncounterPaths = append(a.EncounterPaths, path)
+	}
+
+	if m.Version >= 5 {
+		a.PlaceID = rd.u16()
+
+		if a.PlaceID > 0 && int(a.PlaceID) <= len(m.Places) {
+			a.Place = m.Places[a.PlaceID-1]
+		}
+	}
+
+	if m.Version >= 7 {
+		a.LadderIDsUp = rd.ids()
+		a.LadderIDsDown = rd.ids()
+	}
+
+	if m.Version >= 8 {
+		a.EarliestOccupyTimes[0] = rd.f32()
+		a.EarliestOccupyTimes[1] = rd.f32()
+	}
+
+	if m.Version >= 11 {
+		for i := range a.LightIntensity {
+			a.LightIntensity[i] = rd.f32()
+		}
+	}
+
+	if m.Version >= 16 {
+		n := rd.u32()
+		for i := uint32(0); i < n && rd.err == nil; i++ {
+			a.VisibleAreas = append(a.VisibleAreas, VisibleArea{
+				ID:         rd.u32(),
+				Attributes: rd.u8(),
+			})
+		}
+
+		a.InheritVisibilityFrom = rd.u32()
+	}
+
+	if m.Version >= 15 {
+		a.ApproachSpots = readApproachSpots(rd)
+	}
+
+	return a
+}
+
+func readApproachSpots(rd *reader) []ApproachSpot {
+	var spots []ApproachSpot
+
+	n := int(rd.u8())
+	for i := 0; i < n && rd.err == nil; i++ {
+		spots = append(spots, ApproachSpot{
+			HereID:        rd.u32(),
+			PrevID:        rd.u32(),
+			PrevToHereHow: rd.u8(),
+			NextID:        rd.u32(),
+			HereToNextHow: rd.u8(),
+		})
+	}
+
+	return spots
+}
+
+func (m *Mesh) readLadder(rd *reader) Ladder {
+	l := Ladder{
+		ID:        rd.u32(),
+		Width:     rd.f32(),
+		Top:       rd.vector(),
+		Bottom:    rd.vector(),
+		Length:    rd.f32(),
+		Direction: rd.u32(),
+	}
+
+	if m.Version == 6 {
+		l.Dangling = rd.u8() != 0
+	}
+
+	l.TopForwardAreaID = rd.u32()
+	l.TopLeftAreaID = rd.u32()
+	l.TopRightAreaID = rd.u32()
+	l.TopBehindAreaID = rd.u32()
+	l.BottomAreaID = rd.u32()
+
+	return l
+}
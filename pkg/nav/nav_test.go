@@ -0,0 +1,112 @@
+package nav_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/nav"
+)
+
+type writer struct {
+	bytes.Buffer
+}
+
+func (w *writer) put(vs ...interface{}) *writer {
+	for _, v := range vs {
+		err := binary.Write(&w.Buffer, binary.LittleEndian, v)
+		if err != nil {
+			panic(err)
+		}
+	}
+
+	return w
+}
+
+func writeArea(w *writer, id uint32, place uint16, connectedTo uint32) {
+	w.put(id, uint32(0x1))                                                   // id, attributes
+	w.put(float32(0), float32(0), float32(10))                               // nw corner
+	w.put(float32(100), float32(50), float32(20))                            // se corner
+	w.put(float32(15), float32(15))                                          // ne z, sw z
+	w.put(uint32(1), connectedTo, uint32(0), uint32(0), uint32(0))           // north: 1 connection, other dirs none
+	w.put(uint8(1), uint32(7), float32(1), float32(2), float32(3), uint8(2)) // 1 hiding spot
+	w.put(uint32(1))                                                         // 1 encounter path
+	w.put(uint32(1), uint8(0), uint32(2), uint8(2))                          // from, from dir, to, to dir
+	w.put(uint8(1), uint32(7), uint8(255))                                   // 1 spot at the end of the path
+	w.put(place)
+	w.put(uint32(1), uint32(3), uint32(0)) // ladders up: [3], down: []
+	w.put(float32(1.5), float32(2.5))      // earliest occupy times
+	w.put(float32(1), float32(1), float32(1), float32(1))
+	w.put(uint32(1), connectedTo, uint8(1), uint32(0))                   // visible areas, inherit visibility
+	w.put(uint8(1), uint32(1), uint32(2), uint8(3), uint32(4), uint8(5)) // 1 approach spot (CS data)
+}
+
+func navFile() []byte {
+	w := new(writer)
+
+	w.put(uint32(nav.Magic), uint32(16), uint32(1), uint32(123456), uint8(1))
+
+	places := []string{"BombsiteA", "Ramp"}
+	w.put(uint16(len(places)))
+	for _, p := range places {
+		w.put(uint16(len(p) + 1))
+		w.put([]byte(p + "\x00"))
+	}
+	w.put(uint8(0)) // has unnamed areas
+
+	w.put(uint32(2))
+	writeArea(w, 1, 1, 2)
+	writeArea(w, 2, 2, 1)
+
+	w.put(uint32(1)) // ladders
+	w.put(uint32(3), float32(20), float32(1), float32(2), float32(100), float32(1), float32(2), float32(0))
+	w.put(float32(100), uint32(2), uint32(2), uint32(0), uint32(0), uint32(0), uint32(1))
+
+	return w.Bytes()
+}
+
+func TestParse(t *testing.T) {
+	m, err := nav.Parse(bytes.NewReader(navFile()))
+	assert.NoError(t, err)
+
+	assert.EqualValues(t, 16, m.Version)
+	assert.EqualValues(t, 1, m.SubVersion)
+	assert.EqualValues(t, 123456, m.BSPSize)
+	assert.True(t, m.Analyzed)
+	assert.Equal(t, []string{"BombsiteA", "Ramp"}, m.Places)
+	assert.Len(t, m.Areas, 2)
+
+	a := m.AreaByID(2)
+	assert.NotNil(t, a)
+	assert.Equal(t, "Ramp", a.Place)
+	assert.Equal(t, nav.Vector{X: 100, Y: 50, Z: 20}, a.SouthEast)
+	assert.Equal(t, []uint32{1}, a.Connections.North)
+	assert.Empty(t, a.Connections.West)
+	assert.Equal(t, []nav.HidingSpot{{ID: 7, Position: nav.Vector{X: 1, Y: 2, Z: 3}, Flags: 2}}, a.HidingSpots)
+	assert.Equal(t, []nav.EncounterSpot{{HidingSpotID: 7, ParametricDistance: 1}}, a.EncounterPaths[0].Spots)
+	assert.Equal(t, []uint32{3}, a.LadderIDsUp)
+	assert.Equal(t, [2]float32{1.5, 2.5}, a.EarliestOccupyTimes)
+	assert.Equal(t, []nav.VisibleArea{{ID: 1, Attributes: 1}}, a.VisibleAreas)
+	assert.Equal(t, []nav.ApproachSpot{{HereID: 1, PrevID: 2, PrevToHereHow: 3, NextID: 4, HereToNextHow: 5}}, a.ApproachSpots)
+
+	assert.Len(t, m.Ladders, 1)
+	assert.EqualValues(t, 2, m.Ladders[0].TopForwardAreaID)
+	assert.EqualValues(t, 1, m.Ladders[0].BottomAreaID)
+
+	assert.Nil(t, m.AreaByID(42))
+}
+
+func TestParseErrors(t *testing.T) {
+	b := navFile()
+
+	_, err := nav.Parse(bytes.NewReader(b[:len(b)-10]))
+	assert.Error(t, err)
+
+	_, err = nav.Parse(bytes.NewReader([]byte{1, 2, 3, 4, 16, 0, 0, 0}))
+	assert.Error(t, err)
+
+	_, err = nav.Parse(bytes.NewReader([]byte{0xce, 0xfa, 0xed, 0xfe, 17, 0, 0, 0}))
+	assert.Error(t, err)
+}
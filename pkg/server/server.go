@@ -0,0 +1,195 @@
+// Package server implements an HTTP server that serves radar overview assets by map name and CRC,
+// like the public service at https://radar-overviews.csgo.saiko.tech.
+//
+// Requests have the form /<map>/<crc>/<file>, where file is one of radar.dds, radar.png, info.txt, info.json or nav.nav.
+// Assets are extracted from the workshop item referenced by the CRC table and cached on disk,
+// workshop items that contain a different version of the map are remembered so they aren't downloaded again.
+package server
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/pkg/errors"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/bsputil"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+var contentTypes = map[string]string{
+	bsputil.AssetRadarDDS: "image/vnd-ms.dds",
+	bsputil.AssetRadarPNG: "image/png",
+	bsputil.AssetInfoTxt:  "text/plain; charset=utf-8",
+	bsputil.AssetInfoJSON: "application/json",
+	bsputil.AssetNav:      "application/octet-stream",
+}
+
+// DownloadFunc downloads a workshop item (a zip file containing the BSP) to w.
+type DownloadFunc func(ctx context.Context, workshopID int, w io.Writer) error
+
+// Server serves radar overview assets, see the package documentation.
+type Server struct {
+	// Download is used to fetch workshop items, defaults to steamapi.DownloadWorkshopItemContext.
+	Download DownloadFunc
+	// Logger receives errors that occurred while handling requests, defaults to the standard logger.
+	Logger *log.Logger
+
+	cacheDir string
+	table    *crc.Table
+
+	mu    sync.Mutex
+	locks map[string]*sync.Mutex
+}
+
+// New creates a server which resolves CRCs using the given table and caches assets in cacheDir.
+func New(table *crc.Table, cacheDir string) *Server {
+	return &Server{
+		Download: steamapi.DownloadWorkshopItemContext,
+		Logger:   log.Default(),
+		cacheDir: cacheDir,
+		table:    table,
+		locks:    make(map[string]*sync.Mutex),
+	}
+}
+
+func (s *Server) lock(key string) func() {
+	s.mu.Lock()
+
+	l, ok := s.locks[key]
+	if !ok {
+		l = new(sync.Mutex)
+		s.locks[key] = l
+	}
+
+	s.mu.Unlock()
+
+	l.Lock()
+
+	return l.Unlock
+}
+
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) != 3 {
+		http.NotFound(w, r)
+		return
+	}
+
+	mapName, crcStr, file := parts[0], parts[1], parts[2]
+
+	contentType, ok := contentTypes[file]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+
+	mapCRC, err := strconv.ParseUint(crcStr, 10, 32)
+	if err != nil {
+		http.NotFound(w, r)
+		return
+	}
+
+	entry, ok := s.table.ByCRC(uint32(mapCRC))
+	if !ok || entry.MapName != mapName {
+		http.NotFound(w, r)
+		return
+	}
+
+	dir, err := s.ensureAssets(r.Context(), entry)
+	if err != nil {
+		s.Logger.Printf("failed to get assets for map %q with CRC %d: %+v", entry.MapName, entry.MapCrc32, err)
+		http.Error(w, http.StatusText(http.StatusBadGateway), http.StatusBadGateway)
+
+		return
+	}
+
+	f, err := os.Open(filepath.Join(dir, file))
+	if os.IsNotExist(err) {
+		http.NotFound(w, r)
+		return
+	} else if err != nil {
+		s.Logger.Printf("failed to open cached asset: %+v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+	defer f.Close()
+
+	stat, err := f.Stat()
+	if err != nil {
+		s.Logger.Printf("failed to stat cached asset: %+v", err)
+		http.Error(w, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+
+		return
+	}
+
+	w.Header().Set("Content-Type", contentType)
+	http.ServeContent(w, r, file, stat.ModTime(), f)
+}
+
+// ensureAssets makes sure the assets for entry are in the cache and returns the cache dir.
+func (s *Server) ensureAssets(ctx context.Context, entry crc.Entry) (string, error) {
+	dir := filepath.Join(s.cacheDir, entry.MapName, fmt.Sprint(entry.MapCrc32))
+
+	unlock := s.lock(dir)
+	defer unlock()
+
+	if bsputil.AssetsComplete(dir) {
+		return dir, nil
+	}
+
+	cached, ok := bsputil.CachedCRCMismatch(dir, entry)
+	if ok {
+		return "", errors.Wrapf(cached, "cached result for workshop item %d", entry.WorkshopID)
+	}
+
+	var buf bytes.Buffer
+
+	err := s.Download(ctx, int(entry.WorkshopID), &buf)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to download workshop item %d", entry.WorkshopID)
+	}
+
+	zipR, err := zip.NewReader(bytes.NewReader(buf.Bytes()), int64(buf.Len()))
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to open workshop item %d as zip", entry.WorkshopID)
+	}
+
+	bspF, err := bsputil.ReadWorkshopBSP(zipR)
+	if err != nil {
+		return "", errors.Wrapf(err, "failed to read BSP from workshop item %d", entry.WorkshopID)
+	}
+
+	err = bsputil.ExtractAssetsAtomic(bspF, entry, dir)
+
+	var mismatchErr *bsputil.CRCMismatchError
+	if errors.As(err, &mismatchErr) {
+		// the workshop item won't change back, so don't download it again for every request
+		cacheErr := bsputil.CacheCRCMismatch(dir, mismatchErr)
+		if cacheErr != nil {
+			s.Logger.Printf("failed to cache CRC mismatch: %+v", cacheErr)
+		}
+
+		return "", err
+	} else if err != nil {
+		return "", errors.Wrap(err, "failed to extract assets")
+	}
+
+	return dir, nil
+}
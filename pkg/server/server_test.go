@@ -0,0 +1,142 @@
+package server_test
+
+import (
+	"bytes"
+	"fmt"
+	"image/png"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/crc"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/server"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+const trainRadarInfo = `"de_train"
+{
+	"material"	"overviews/de_train"
+	"pos_x"		"-2477"
+	"pos_y"		"2392"
+	"scale"		"4.7"
+}
+`
+
+var (
+	trainBSP = fixture.MapBSP(map[string][]byte{
+		"resource/overviews/de_train.txt":       []byte(trainRadarInfo),
+		"resource/overviews/de_train_radar.dds": fixture.DDS(),
+		"maps/de_train.nav":                     []byte("nav"),
+	})
+	trainCRC = fixture.MapCRC(trainBSP)
+	// trainPath is the URL path of the de_train assets
+	trainPath = fmt.Sprintf("/de_train/%d", trainCRC)
+
+	table = crc.NewTable([]crc.Entry{
+		{MapName: "de_train", MapCrc32: trainCRC, WorkshopID: 1},
+		{MapName: "de_broken", MapCrc32: 5678, WorkshopID: 2},
+		// the workshop item contains a different version of the map
+		{MapName: "de_train", MapCrc32: 4321, WorkshopID: 3},
+	})
+)
+
+func workshopItem() []byte {
+	return fixture.Zip(map[string][]byte{"de_train.bsp": trainBSP})
+}
+
+func newServer(t *testing.T) (*httptest.Server, *fixture.SteamAPI) {
+	t.Helper()
+
+	api := fixture.NewSteamAPI(map[int][]byte{1: workshopItem(), 3: workshopItem()})
+	t.Cleanup(api.Close)
+
+	s := server.New(table, t.TempDir())
+	s.Logger = log.New(ioutil.Discard, "", 0)
+	s.Download = (&steamapi.Client{BaseURL: api.URL}).DownloadWorkshopItemContext
+
+	srv := httptest.NewServer(s)
+	t.Cleanup(srv.Close)
+
+	return srv, api
+}
+
+func get(t *testing.T, url string) (*http.Response, []byte) {
+	t.Helper()
+
+	resp, err := http.Get(url)
+	assert.NoError(t, err)
+	defer resp.Body.Close()
+
+	b, err := ioutil.ReadAll(resp.Body)
+	assert.NoError(t, err)
+
+	return resp, b
+}
+
+func TestServe(t *testing.T) {
+	srv, api := newServer(t)
+
+	resp, b := get(t, srv.URL+trainPath+"/info.txt")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, trainRadarInfo, string(b))
+
+	resp, b = get(t, srv.URL+trainPath+"/info.json")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "application/json", resp.Header.Get("Content-Type"))
+	assert.JSONEq(t, `{"de_train": {"material": "overviews/de_train", "pos_x": "-2477", "pos_y": "2392", "scale": "4.7"}}`, string(b))
+
+	resp, b = get(t, srv.URL+trainPath+"/radar.dds")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, fixture.DDS(), b)
+
+	resp, b = get(t, srv.URL+trainPath+"/radar.png")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+
+	img, err := png.Decode(bytes.NewReader(b))
+	assert.NoError(t, err)
+	assert.Equal(t, 4, img.Bounds().Dx())
+
+	resp, b = get(t, srv.URL+trainPath+"/nav.nav")
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.Equal(t, "nav", string(b))
+
+	assert.Equal(t, 1, api.Downloads(1), "assets should be cached after the first request")
+}
+
+func TestServeErrors(t *testing.T) {
+	srv, _ := newServer(t)
+
+	for _, path := range []string{
+		trainPath + "/other.txt",
+		"/de_train/9999/radar.dds",
+		fmt.Sprintf("/de_dust2/%d/radar.dds", trainCRC),
+		"/de_train/abc/radar.dds",
+		trainPath,
+	} {
+		resp, _ := get(t, srv.URL+path)
+		assert.Equal(t, http.StatusNotFound, resp.StatusCode, path)
+	}
+
+	resp, _ := get(t, srv.URL+"/de_broken/5678/radar.dds")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+
+	// assets of the wrong map version are never served
+	resp, _ = get(t, srv.URL+"/de_train/4321/radar.dds")
+	assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+}
+
+func TestServeCRCMismatchCached(t *testing.T) {
+	srv, api := newServer(t)
+
+	for i := 0; i < 3; i++ {
+		resp, _ := get(t, srv.URL+"/de_train/4321/radar.dds")
+		assert.Equal(t, http.StatusBadGateway, resp.StatusCode)
+	}
+
+	assert.Equal(t, 1, api.Downloads(3), "the CRC mismatch should be cached")
+}
@@ -0,0 +1,259 @@
+package steamapi
+
+import (
+	"archive/zip"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/pkg/errors"
+)
+
+// ProgressFunc is called while downloading with the number of bytes of the file that are on disk
+// and the expected total size (0 if unknown).
+type ProgressFunc func(written, total int64)
+
+// SizeMismatchError is returned if a downloaded file's size doesn't match the file_size reported by the Steam API.
+type SizeMismatchError struct {
+	WorkshopID string
+	Expected   int64
+	Actual     int64
+}
+
+func (e *SizeMismatchError) Error() string {
+	return fmt.Sprintf("downloaded %d bytes for workshop item %s, expected %d", e.Actual, e.WorkshopID, e.Expected)
+}
+
+func (d PublishedFileDetails) verifySize(n int64) error {
+	if d.FileSize > 0 && n != int64(d.FileSize) {
+		return &SizeMismatchError{
+			WorkshopID: d.Publishedfileid,
+			Expected:   int64(d.FileSize),
+			Actual:     n,
+		}
+	}
+
+	return nil
+}
+
+// getFile requests a file from the workshop CDN, starting at offset if > 0.
+func (c *Client) getFile(ctx context.Context, fileURL string, offset int64) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, fileURL, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if offset > 0 {
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-", offset))
+	}
+
+	return c.do(req)
+}
+
+// copyContext is io.Copy, but reports a cancellation of ctx rather than whatever the interrupted read returned.
+func copyContext(ctx context.Context, w io.Writer, r io.Reader) (int64, error) {
+	n, err := io.Copy(w, r)
+	if err != nil && ctx.Err() != nil {
+		return n, ctx.Err()
+	}
+
+	return n, err
+}
+
+type progressWriter struct {
+	w        io.Writer
+	written  int64
+	total    int64
+	progress ProgressFunc
+}
+
+func (pw *progressWriter) Write(p []byte) (int, error) {
+	n, err := pw.w.Write(p)
+	pw.written += int64(n)
+
+	if pw.progress != nil {
+		pw.progress(pw.written, pw.total)
+	}
+
+	return n, err
+}
+
+// DownloadWorkshopItemToFile downloads a workshop item to path.
+//
+// Data is first written to path + ".part". If that file already exists (e.g. from an interrupted download),
+// the download is resumed via an HTTP range request.
+// Once complete, the size is verified against the size reported by the Steam API
+// and the checksums of all files in the zip archive are checked before it's moved to path.
+// progress may be nil.
+func (c *Client) DownloadWorkshopItemToFile(ctx context.Context, workshopID int, path string, progress ProgressFunc) error {
+	item, err := c.workshopItem(ctx, workshopID)
+	if err != nil {
+		return err
+	}
+
+	partPath := path + ".part"
+
+	f, err := os.OpenFile(partPath, os.O_CREATE|os.O_WRONLY, 0666)
+	if err != nil {
+		return errors.Wrapf(err, "failed to open partial download file %q", partPath)
+	}
+	defer f.Close()
+
+	offset, err := f.Seek(0, io.SeekEnd)
+	if err != nil {
+		return errors.Wrapf(err, "failed to seek to end of partial download file %q", partPath)
+	}
+
+	total := int64(item.FileSize)
+
+	if total > 0 && offset > total {
+		// can't be a prefix of the file we want - start over
+		offset, err = restart(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	if total == 0 || offset < total {
+		offset, err = c.downloadFrom(ctx, item, f, offset, progress)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = f.Close()
+	if err != nil {
+		return errors.Wrapf(err, "failed to close partial download file %q", partPath)
+	}
+
+	err = item.verifySize(offset)
+	if err != nil {
+		if offset > total {
+			os.Remove(partPath)
+		}
+
+		return err
+	}
+
+	err = verifyZip(partPath)
+	if err != nil {
+		// the data is corrupt, resuming would only append to garbage
+		os.Remove(partPath)
+
+		return errors.Wrapf(err, "downloaded file for workshop item %d is corrupt", workshopID)
+	}
+
+	err = os.Rename(partPath, path)
+	if err != nil {
+		return errors.Wrapf(err, "failed to move downloaded file to %q", path)
+	}
+
+	return nil
+}
+
+func restart(f *os.File) (int64, error) {
+	err := f.Truncate(0)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to truncate partial download file %q", f.Name())
+	}
+
+	_, err = f.Seek(0, io.SeekStart)
+	if err != nil {
+		return 0, errors.Wrapf(err, "failed to seek to start of partial download file %q", f.Name())
+	}
+
+	return 0, nil
+}
+
+// downloadFrom appends the file of item to f starting at offset and returns the new size of f.
+func (c *Client) downloadFrom(ctx context.Context, item PublishedFileDetails, f *os.File, offset int64, progress ProgressFunc) (int64, error) {
+	resp, err := c.getFile(ctx, item.FileURL, offset)
+
+	var statusErr *HTTPStatusError
+	if offset > 0 && errors.As(err, &statusErr) && statusErr.StatusCode == http.StatusRequestedRangeNotSatisfiable {
+		// without file_size a complete partial file is only noticed when requesting the rest of it
+		if statusErr.header.Get("Content-Range") == fmt.Sprintf("bytes */%d", offset) {
+			return offset, nil
+		}
+
+		offset, err = restart(f)
+		if err != nil {
+			return offset, err
+		}
+
+		return c.downloadFrom(ctx, item, f, 0, progress)
+	}
+
+	if err != nil {
+		return offset, err
+	}
+	defer resp.Body.Close()
+
+	if offset > 0 && (resp.StatusCode != http.StatusPartialContent || !strings.HasPrefix(resp.Header.Get("Content-Range"), fmt.Sprintf("bytes %d-", offset))) {
+		// server doesn't support (this) range request and sent the whole file
+		offset, err = restart(f)
+		if err != nil {
+			return offset, err
+		}
+
+		if resp.StatusCode == http.StatusPartialContent {
+			// partial content, but not from where we need it - request the whole file instead
+			resp.Body.Close()
+
+			return c.downloadFrom(ctx, item, f, 0, progress)
+		}
+	}
+
+	pw := &progressWriter{
+		w:        f,
+		written:  offset,
+		total:    int64(item.FileSize),
+		progress: progress,
+	}
+
+	_, err = copyContext(ctx, pw, resp.Body)
+
+	return pw.written, err
+}
+
+// verifyZip checks the CRC32 checksums of all files in a zip archive.
+// Workshop items are always zip archives, so anything else (e.g. a truncated archive) fails the verification.
+func verifyZip(path string) error {
+	zipR, err := zip.OpenReader(path)
+	if err != nil {
+		return errors.Wrap(err, "failed to open zip archive")
+	}
+	defer zipR.Close()
+
+	for _, f := range zipR.File {
+		err := verifyZipFile(f)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func verifyZipFile(f *zip.File) error {
+	r, err := f.Open()
+	if err != nil {
+		return errors.Wrapf(err, "failed to open file %q in zip archive", f.Name)
+	}
+	defer r.Close()
+
+	// archive/zip verifies the checksum when reaching EOF
+	_, err = io.Copy(io.Discard, r)
+	if err != nil {
+		return errors.Wrapf(err, "failed to verify file %q in zip archive", f.Name)
+	}
+
+	return nil
+}
+
+func DownloadWorkshopItemToFile(ctx context.Context, workshopID int, path string, progress ProgressFunc) error {
+	return DefaultClient.DownloadWorkshopItemToFile(ctx, workshopID, path, progress)
+}
@@ -0,0 +1,202 @@
+package steamapi_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
+)
+
+func TestDownloadWorkshopItemToFileResume(t *testing.T) {
+	data := fixture.Zip(map[string][]byte{"de_test.bsp": []byte(strings.Repeat("bsp data ", 1000))})
+
+	api := fixture.NewSteamAPI(map[int][]byte{workshopID: data})
+	defer api.Close()
+
+	path := filepath.Join(t.TempDir(), "de_test.bsp.zip")
+
+	// simulate an interrupted download
+	half := len(data) / 2
+	err := ioutil.WriteFile(path+".part", data[:half], 0666)
+	assert.NoError(t, err)
+
+	var lastWritten, lastTotal int64
+
+	client := &steamapi.Client{BaseURL: api.URL}
+
+	err = client.DownloadWorkshopItemToFile(context.Background(), workshopID, path, func(written, total int64) {
+		lastWritten, lastTotal = written, total
+	})
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+
+	assert.Equal(t, []string{"bytes=" + strconv.Itoa(half) + "-"}, api.Ranges())
+	assert.Equal(t, int64(len(data)), lastWritten)
+	assert.Equal(t, int64(len(data)), lastTotal)
+
+	_, err = os.Stat(path + ".part")
+	assert.True(t, os.IsNotExist(err), "partial file should be gone")
+}
+
+// lyingServer reports fileSize in the details, but always serves body in full (ignoring Range headers).
+func lyingServer(t *testing.T, fileSize int, body []byte) *steamapi.Client {
+	t.Helper()
+
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/file" {
+			_, _ = w.Write(body)
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"response": {"result": 1, "resultcount": 1, "publishedfiledetails": [{"publishedfileid": "1", "result": 1, "file_size": ` +
+			strconv.Itoa(fileSize) + `, "file_url": "` + srv.URL + `/file"}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return &steamapi.Client{BaseURL: srv.URL}
+}
+
+func TestDownloadWorkshopItemToFileRangeIgnored(t *testing.T) {
+	data := fixture.Zip(map[string][]byte{"de_test.bsp": []byte("bsp data")})
+	path := filepath.Join(t.TempDir(), "de_test.bsp.zip")
+
+	err := ioutil.WriteFile(path+".part", data[:10], 0666)
+	assert.NoError(t, err)
+
+	err = lyingServer(t, len(data), data).DownloadWorkshopItemToFile(context.Background(), workshopID, path, nil)
+	assert.NoError(t, err)
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestDownloadWorkshopItemToFileSizeMismatch(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "de_test.bsp.zip")
+
+	err := lyingServer(t, 100, []byte("truncated")).DownloadWorkshopItemToFile(context.Background(), workshopID, path, nil)
+
+	var sizeErr *steamapi.SizeMismatchError
+	assert.ErrorAs(t, err, &sizeErr)
+	assert.Equal(t, int64(100), sizeErr.Expected)
+	assert.Equal(t, int64(9), sizeErr.Actual)
+
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err), "incomplete download must not be moved to the target path")
+
+	_, err = os.Stat(path + ".part")
+	assert.NoError(t, err, "partial file should be kept for resuming")
+}
+
+func TestDownloadWorkshopItemSizeMismatch(t *testing.T) {
+	err := lyingServer(t, 100, []byte("truncated")).DownloadWorkshopItem(workshopID, ioutil.Discard)
+
+	var sizeErr *steamapi.SizeMismatchError
+	assert.ErrorAs(t, err, &sizeErr)
+}
+
+func TestDownloadWorkshopItemToFileCorrupt(t *testing.T) {
+	data := fixture.Zip(map[string][]byte{"de_test.bsp": []byte(strings.Repeat("bsp data ", 100))})
+	corrupt := append([]byte(nil), data...)
+	corrupt[50] ^= 0xff // inside the compressed file data
+
+	path := filepath.Join(t.TempDir(), "de_test.bsp.zip")
+
+	err := lyingServer(t, len(corrupt), corrupt).DownloadWorkshopItemToFile(context.Background(), workshopID, path, nil)
+	assert.Error(t, err)
+
+	_, err = os.Stat(path + ".part")
+	assert.True(t, os.IsNotExist(err), "corrupt partial file should be removed")
+}
+
+// unknownSizeServer serves body with range support, but doesn't report its file_size in the details.
+// It returns the number of file requests made.
+func unknownSizeServer(t *testing.T, body []byte) (*steamapi.Client, *int) {
+	t.Helper()
+
+	var (
+		srv      *httptest.Server
+		requests int
+	)
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/file" {
+			requests++
+			http.ServeContent(w, r, "", time.Time{}, bytes.NewReader(body))
+
+			return
+		}
+
+		_, _ = w.Write([]byte(`{"response": {"result": 1, "resultcount": 1, "publishedfiledetails": [{"publishedfileid": "1", "result": 1, "file_url": "` +
+			srv.URL + `/file"}]}}`))
+	}))
+	t.Cleanup(srv.Close)
+
+	return &steamapi.Client{BaseURL: srv.URL}, &requests
+}
+
+func TestDownloadWorkshopItemToFileUnknownSizeComplete(t *testing.T) {
+	data := fixture.Zip(map[string][]byte{"de_test.bsp": []byte("bsp data")})
+	path := filepath.Join(t.TempDir(), "de_test.bsp.zip")
+
+	// e.g. interrupted after the last byte was written but before the file was moved
+	err := ioutil.WriteFile(path+".part", data, 0666)
+	assert.NoError(t, err)
+
+	client, requests := unknownSizeServer(t, data)
+
+	err = client.DownloadWorkshopItemToFile(context.Background(), workshopID, path, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, *requests, "the 416 response to the range request should complete the download")
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestDownloadWorkshopItemToFileUnknownSizeTooLong(t *testing.T) {
+	data := fixture.Zip(map[string][]byte{"de_test.bsp": []byte("bsp data")})
+	path := filepath.Join(t.TempDir(), "de_test.bsp.zip")
+
+	err := ioutil.WriteFile(path+".part", append(append([]byte(nil), data...), "garbage"...), 0666)
+	assert.NoError(t, err)
+
+	client, requests := unknownSizeServer(t, data)
+
+	err = client.DownloadWorkshopItemToFile(context.Background(), workshopID, path, nil)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, *requests, "the file should be downloaded again from the start")
+
+	b, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, data, b)
+}
+
+func TestDownloadWorkshopItemToFileTruncatedZip(t *testing.T) {
+	data := fixture.Zip(map[string][]byte{"de_test.bsp": []byte(strings.Repeat("bsp data ", 100))})
+	path := filepath.Join(t.TempDir(), "de_test.bsp.zip")
+
+	// without file_size the truncation is only detected by the zip verification
+	err := lyingServer(t, 0, data[:len(data)/2]).DownloadWorkshopItemToFile(context.Background(), workshopID, path, nil)
+	assert.ErrorIs(t, err, zip.ErrFormat)
+	assert.NoFileExists(t, path)
+	assert.NoFileExists(t, path+".part")
+}
@@ -0,0 +1,105 @@
+package steamapi
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/pkg/errors"
+)
+
+// EResult codes as returned by the Steam API, see https://partner.steamgames.com/doc/api/steam_api#EResult
+const (
+	ResultOK           = 1
+	ResultFail         = 2
+	ResultInvalidParam = 8
+	ResultFileNotFound = 9
+	ResultAccessDenied = 15
+	ResultBanned       = 17
+)
+
+var (
+	// ErrItemNotFound is returned if a workshop item doesn't exist or has been removed.
+	ErrItemNotFound = errors.New("workshop item not found")
+	// ErrItemBanned is returned if a workshop item has been banned.
+	ErrItemBanned = errors.New("workshop item is banned")
+	// ErrAccessDenied is returned if a workshop item isn't publicly accessible.
+	ErrAccessDenied = errors.New("access to workshop item denied")
+)
+
+// ResultError is returned when the Steam API reports an EResult other than OK.
+// Use errors.Is with ErrItemNotFound, ErrItemBanned and ErrAccessDenied to check for common cases.
+type ResultError struct {
+	WorkshopID string
+	Result     int
+}
+
+func (e *ResultError) Error() string {
+	if e.WorkshopID == "" {
+		return fmt.Sprintf("steam API request failed with result %d", e.Result)
+	}
+
+	return fmt.Sprintf("steam API returned result %d for workshop item %s", e.Result, e.WorkshopID)
+}
+
+func (e *ResultError) Is(target error) bool {
+	switch target {
+	case ErrItemNotFound:
+		return e.Result == ResultFileNotFound
+	case ErrAccessDenied:
+		return e.Result == ResultAccessDenied
+	case ErrItemBanned:
+		return e.Result == ResultBanned
+	}
+
+	return false
+}
+
+// BannedError is returned for workshop items that have been banned.
+// errors.Is(err, ErrItemBanned) is true for it.
+type BannedError struct {
+	WorkshopID string
+	Reason     string
+}
+
+func (e *BannedError) Error() string {
+	if e.Reason == "" {
+		return fmt.Sprintf("workshop item %s is banned", e.WorkshopID)
+	}
+
+	return fmt.Sprintf("workshop item %s is banned: %s", e.WorkshopID, e.Reason)
+}
+
+func (e *BannedError) Is(target error) bool {
+	return target == ErrItemBanned
+}
+
+// HTTPStatusError is returned when a request to the Steam API or the workshop CDN fails with a non-2xx status.
+type HTTPStatusError struct {
+	URL        string
+	StatusCode int
+	Status     string
+
+	// header of the response, e.g. the Content-Range of 416 responses to range requests
+	header http.Header
+}
+
+func (e *HTTPStatusError) Error() string {
+	return fmt.Sprintf("request to %q failed with HTTP status %s", e.URL, e.Status)
+}
+
+// Err returns an error if the details indicate that the item can't be downloaded, e.g. because it doesn't exist or has been banned.
+func (d PublishedFileDetails) Err() error {
+	if d.Result != ResultOK {
+		return &ResultError{WorkshopID: d.Publishedfileid, Result: d.Result}
+	}
+
+	if d.Banned != 0 {
+		return &BannedError{WorkshopID: d.Publishedfileid, Reason: d.BanReason}
+	}
+
+	if d.FileURL == "" {
+		return errors.Wrapf(ErrItemNotFound, "no file URL for workshop item %s", d.Publishedfileid)
+	}
+
+	return nil
+}
@@ -1,55 +1,167 @@
 package steamapi
 
 import (
+	"context"
 	"encoding/json"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
 )
 
+type PublishedFileDetails struct {
+	Publishedfileid       string `json:"publishedfileid"`
+	Result                int    `json:"result"`
+	Creator               string `json:"creator"`
+	CreatorAppID          int    `json:"creator_app_id"`
+	ConsumerAppID         int    `json:"consumer_app_id"`
+	Filename              string `json:"filename"`
+	FileSize              int    `json:"file_size"`
+	FileURL               string `json:"file_url"`
+	HcontentFile          string `json:"hcontent_file"`
+	PreviewURL            string `json:"preview_url"`
+	HcontentPreview       string `json:"hcontent_preview"`
+	Title                 string `json:"title"`
+	Description           string `json:"description"`
+	TimeCreated           int    `json:"time_created"`
+	TimeUpdated           int    `json:"time_updated"`
+	Visibility            int    `json:"visibility"`
+	Banned                int    `json:"banned"`
+	BanReason             string `json:"ban_reason"`
+	Subscriptions         int    `json:"subscriptions"`
+	Favorited             int    `json:"favorited"`
+	LifetimeSubscriptions int    `json:"lifetime_subscriptions"`
+	LifetimeFavorited     int    `json:"lifetime_favorited"`
+	Views                 int    `json:"views"`
+	Tags                  []struct {
+		Tag string `json:"tag"`
+	} `json:"tags"`
+}
+
 type GetPublishedFileDetailsResponse struct {
 	Response struct {
-		Result               int `json:"result"`
-		Resultcount          int `json:"resultcount"`
-		Publishedfiledetails []struct {
-			Publishedfileid       string `json:"publishedfileid"`
-			Result                int    `json:"result"`
-			Creator               string `json:"creator"`
-			CreatorAppID          int    `json:"creator_app_id"`
-			ConsumerAppID         int    `json:"consumer_app_id"`
-			Filename              string `json:"filename"`
-			FileSize              int    `json:"file_size"`
-			FileURL               string `json:"file_url"`
-			HcontentFile          string `json:"hcontent_file"`
-			PreviewURL            string `json:"preview_url"`
-			HcontentPreview       string `json:"hcontent_preview"`
-			Title                 string `json:"title"`
-			Description           string `json:"description"`
-			TimeCreated           int    `json:"time_created"`
-			TimeUpdated           int    `json:"time_updated"`
-			Visibility            int    `json:"visibility"`
-			Banned                int    `json:"banned"`
-			BanReason             string `json:"ban_reason"`
-			Subscriptions         int    `json:"subscriptions"`
-			Favorited             int    `json:"favorited"`
-			LifetimeSubscriptions int    `json:"lifetime_subscriptions"`
-			LifetimeFavorited     int    `json:"lifetime_favorited"`
-			Views                 int    `json:"views"`
-			Tags                  []struct {
-				Tag string `json:"tag"`
-			} `json:"tags"`
-		} `json:"publishedfiledetails"`
+		Result               int                    `json:"result"`
+		Resultcount          int                    `json:"resultcount"`
+		Publishedfiledetails []PublishedFileDetails `json:"publishedfiledetails"`
 	} `json:"response"`
 }
 
-func GetWorkshopFileDetails(workshopID int) (GetPublishedFileDetailsResponse, error) {
+// DefaultBaseURL is the base URL of the public Steam Web API.
+const DefaultBaseURL = "http://api.steampowered.com"
+
+// Client is a Steam Web API client.
+// The zero value is usable and behaves like DefaultClient.
+type Client struct {
+	// BaseURL is the base URL of the Steam Web API, defaults to DefaultBaseURL.
+	BaseURL string
+	// HTTPClient is used for all requests, defaults to http.DefaultClient.
+	HTTPClient *http.Client
+	// UserAgent is sent with all requests if set.
+	UserAgent string
+	// APIKey is sent with API requests if set, it's not required for workshop details.
+	APIKey string
+	// BatchSize is the maximum number of items per GetPublishedFileDetails request of batch lookups, defaults to DefaultBatchSize.
+	BatchSize int
+}
+
+// DefaultBatchSize is the default number of workshop items requested at once by batch lookups.
+const DefaultBatchSize = 100
+
+// NewClient returns a client with default settings.
+func NewClient() *Client {
+	return &Client{
+		BaseURL:    DefaultBaseURL,
+		HTTPClient: http.DefaultClient,
+		BatchSize:  DefaultBatchSize,
+	}
+}
+
+// DefaultClient is used by the package level functions.
+var DefaultClient = NewClient()
+
+func (c *Client) baseURL() string {
+	if c.BaseURL == "" {
+		return DefaultBaseURL
+	}
+
+	return strings.TrimSuffix(c.BaseURL, "/")
+}
+
+func (c *Client) httpClient() *http.Client {
+	if c.HTTPClient == nil {
+		return http.DefaultClient
+	}
+
+	return c.HTTPClient
+}
+
+func (c *Client) batchSize() int {
+	if c.BatchSize <= 0 {
+		return DefaultBatchSize
+	}
+
+	return c.BatchSize
+}
+
+// do sends a request and returns an *HTTPStatusError for non-2xx responses.
+func (c *Client) do(req *http.Request) (*http.Response, error) {
+	if c.UserAgent != "" {
+		req.Header.Set("User-Agent", c.UserAgent)
+	}
+
+	resp, err := c.httpClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		resp.Body.Close()
+
+		return nil, &HTTPStatusError{
+			URL:        req.URL.Redacted(),
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			header:     resp.Header,
+		}
+	}
+
+	return resp, nil
+}
+
+func (c *Client) GetWorkshopFileDetails(workshopID int) (GetPublishedFileDetailsResponse, error) {
+	return c.GetWorkshopFileDetailsContext(context.Background(), workshopID)
+}
+
+// GetWorkshopFileDetailsContext is like GetWorkshopFileDetails but aborts the request when ctx is done.
+func (c *Client) GetWorkshopFileDetailsContext(ctx context.Context, workshopID int) (GetPublishedFileDetailsResponse, error) {
+	return c.getPublishedFileDetails(ctx, []int{workshopID})
+}
+
+func (c *Client) getPublishedFileDetails(ctx context.Context, workshopIDs []int) (GetPublishedFileDetailsResponse, error) {
 	payload := url.Values{
-		"itemcount":           []string{"1"},
-		"publishedfileids[0]": []string{fmt.Sprint(workshopID)},
+		"itemcount": []string{fmt.Sprint(len(workshopIDs))},
+	}
+
+	for i, id := range workshopIDs {
+		payload.Set(fmt.Sprintf("publishedfileids[%d]", i), fmt.Sprint(id))
+	}
+
+	if c.APIKey != "" {
+		payload.Set("key", c.APIKey)
 	}
 
-	resp, err := http.PostForm("http://api.steampowered.com/ISteamRemoteStorage/GetPublishedFileDetails/v1", payload)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, c.baseURL()+"/ISteamRemoteStorage/GetPublishedFileDetails/v1", strings.NewReader(payload.Encode()))
+	if err != nil {
+		return GetPublishedFileDetailsResponse{}, err
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := c.do(req)
 	if err != nil {
 		return GetPublishedFileDetailsResponse{}, err
 	}
@@ -60,25 +172,128 @@ func GetWorkshopFileDetails(workshopID int) (GetPublishedFileDetailsResponse, er
 	dec := json.NewDecoder(resp.Body)
 	err = dec.Decode(&respData)
 	if err != nil {
-		return GetPublishedFileDetailsResponse{}, err
+		return GetPublishedFileDetailsResponse{}, errors.Wrap(err, "failed to decode GetPublishedFileDetails response")
+	}
+
+	if respData.Response.Result != ResultOK {
+		return GetPublishedFileDetailsResponse{}, &ResultError{Result: respData.Response.Result}
 	}
 
 	return respData, nil
 }
 
-func DownloadWorkshopItem(workshopID int, w io.Writer) error {
-	details, err := GetWorkshopFileDetails(workshopID)
+// GetWorkshopFileDetailsBatch fetches the details of many workshop items, split into requests of at most BatchSize items.
+// The result is keyed by workshop ID, use PublishedFileDetails.Result or Err() to check the status of each item.
+// IDs for which Steam returned no details at all are missing from the result.
+func (c *Client) GetWorkshopFileDetailsBatch(workshopIDs []int) (map[int]PublishedFileDetails, error) {
+	return c.GetWorkshopFileDetailsBatchContext(context.Background(), workshopIDs)
+}
+
+// GetWorkshopFileDetailsBatchContext is like GetWorkshopFileDetailsBatch but aborts when ctx is done.
+func (c *Client) GetWorkshopFileDetailsBatchContext(ctx context.Context, workshopIDs []int) (map[int]PublishedFileDetails, error) {
+	var (
+		res  = make(map[int]PublishedFileDetails, len(workshopIDs))
+		seen = make(map[int]bool, len(workshopIDs))
+		ids  = make([]int, 0, len(workshopIDs))
+	)
+
+	for _, id := range workshopIDs {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+
+	for start := 0; start < len(ids); start += c.batchSize() {
+		end := start + c.batchSize()
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		resp, err := c.getPublishedFileDetails(ctx, ids[start:end])
+		if err != nil {
+			return nil, errors.Wrapf(err, "failed to get details for workshop items %d to %d", start, end-1)
+		}
+
+		for _, d := range resp.Response.Publishedfiledetails {
+			id, err := strconv.Atoi(d.Publishedfileid)
+			if err != nil {
+				return nil, errors.Wrapf(err, "invalid publishedfileid %q in response", d.Publishedfileid)
+			}
+
+			res[id] = d
+		}
+	}
+
+	return res, nil
+}
+
+func (c *Client) DownloadWorkshopItem(workshopID int, w io.Writer) error {
+	return c.DownloadWorkshopItemContext(context.Background(), workshopID, w)
+}
+
+// DownloadWorkshopItemContext is like DownloadWorkshopItem but aborts both the details request and the file transfer when ctx is done.
+func (c *Client) DownloadWorkshopItemContext(ctx context.Context, workshopID int, w io.Writer) error {
+	item, err := c.workshopItem(ctx, workshopID)
 	if err != nil {
 		return err
 	}
 
-	resp, err := http.Get(details.Response.Publishedfiledetails[0].FileURL)
+	resp, err := c.getFile(ctx, item.FileURL, 0)
 	if err != nil {
 		return err
 	}
 	defer resp.Body.Close()
 
-	_, err = io.Copy(w, resp.Body)
+	n, err := copyContext(ctx, w, resp.Body)
+	if err != nil {
+		return err
+	}
+
+	return item.verifySize(n)
+}
+
+// workshopItem returns the details of a single workshop item, or an error if it can't be downloaded.
+func (c *Client) workshopItem(ctx context.Context, workshopID int) (PublishedFileDetails, error) {
+	details, err := c.GetWorkshopFileDetailsContext(ctx, workshopID)
+	if err != nil {
+		return PublishedFileDetails{}, err
+	}
+
+	if details.Response.Resultcount == 0 || len(details.Response.Publishedfiledetails) == 0 {
+		return PublishedFileDetails{}, errors.Wrapf(ErrItemNotFound, "no details returned for workshop item %d", workshopID)
+	}
+
+	item := details.Response.Publishedfiledetails[0]
+
+	err = item.Err()
+	if err != nil {
+		return PublishedFileDetails{}, err
+	}
+
+	return item, nil
+}
+
+func GetWorkshopFileDetails(workshopID int) (GetPublishedFileDetailsResponse, error) {
+	return DefaultClient.GetWorkshopFileDetails(workshopID)
+}
+
+func DownloadWorkshopItem(workshopID int, w io.Writer) error {
+	return DefaultClient.DownloadWorkshopItem(workshopID, w)
+}
+
+func GetWorkshopFileDetailsContext(ctx context.Context, workshopID int) (GetPublishedFileDetailsResponse, error) {
+	return DefaultClient.GetWorkshopFileDetailsContext(ctx, workshopID)
+}
+
+func DownloadWorkshopItemContext(ctx context.Context, workshopID int, w io.Writer) error {
+	return DefaultClient.DownloadWorkshopItemContext(ctx, workshopID, w)
+}
+
+func GetWorkshopFileDetailsBatch(workshopIDs []int) (map[int]PublishedFileDetails, error) {
+	return DefaultClient.GetWorkshopFileDetailsBatch(workshopIDs)
+}
 
-	return err
+func GetWorkshopFileDetailsBatchContext(ctx context.Context, workshopIDs []int) (map[int]PublishedFileDetails, error) {
+	return DefaultClient.GetWorkshopFileDetailsBatchContext(ctx, workshopIDs)
 }
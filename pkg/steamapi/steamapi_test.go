@@ -1,16 +1,210 @@
 package steamapi_test
 
 import (
-	"fmt"
+	"bytes"
+	"context"
+	"net/http"
+	"net/http/httptest"
 	"testing"
+	"time"
 
-	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
 	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/internal/fixture"
+	"github.com/saiko-tech/csgo-centrifuge/pkg/steamapi"
 )
 
+const workshopID = 472138951
+
 func TestX(t *testing.T) {
-	resp, err := steamapi.GetWorkshopFileDetails(472138951)
+	api := fixture.NewSteamAPI(map[int][]byte{workshopID: []byte("zip data")})
+	defer api.Close()
+
+	client := &steamapi.Client{BaseURL: api.URL}
+
+	resp, err := client.GetWorkshopFileDetails(workshopID)
+	assert.NoError(t, err)
+
+	assert.Len(t, resp.Response.Publishedfiledetails, 1)
+	assert.Equal(t, "472138951", resp.Response.Publishedfiledetails[0].Publishedfileid)
+	assert.Equal(t, 8, resp.Response.Publishedfiledetails[0].FileSize)
+}
+
+func TestDownloadWorkshopItem(t *testing.T) {
+	api := fixture.NewSteamAPI(map[int][]byte{workshopID: []byte("zip data")})
+	defer api.Close()
+
+	client := &steamapi.Client{BaseURL: api.URL, HTTPClient: api.Client()}
+
+	var buf bytes.Buffer
+
+	err := client.DownloadWorkshopItem(workshopID, &buf)
+	assert.NoError(t, err)
+	assert.Equal(t, "zip data", buf.String())
+}
+
+func TestClientOptions(t *testing.T) {
+	var req *http.Request
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = r.ParseForm()
+		req = r
+
+		_, _ = w.Write([]byte(`{"response": {"result": 1, "resultcount": 0, "publishedfiledetails": []}}`))
+	}))
+	defer srv.Close()
+
+	client := &steamapi.Client{
+		BaseURL:   srv.URL + "/",
+		UserAgent: "csgo-centrifuge-test",
+		APIKey:    "secret",
+	}
+
+	_, err := client.GetWorkshopFileDetails(workshopID)
 	assert.NoError(t, err)
 
-	fmt.Println(resp)
+	assert.Equal(t, "/ISteamRemoteStorage/GetPublishedFileDetails/v1", req.URL.Path)
+	assert.Equal(t, "csgo-centrifuge-test", req.Header.Get("User-Agent"))
+	assert.Equal(t, "secret", req.PostForm.Get("key"))
+	assert.Equal(t, "472138951", req.PostForm.Get("publishedfileids[0]"))
+}
+
+func TestDownloadWorkshopItemContextTimeout(t *testing.T) {
+	var srv *httptest.Server
+
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/file" {
+			_, _ = w.Write([]byte(`{"response": {"result": 1, "resultcount": 1, "publishedfiledetails": [{"result": 1, "file_url": "` + srv.URL + `/file"}]}}`))
+			return
+		}
+
+		// simulate a stalled transfer
+		_, _ = w.Write([]byte("partial"))
+		w.(http.Flusher).Flush()
+
+		<-r.Context().Done()
+	}))
+	defer srv.Close()
+
+	client := &steamapi.Client{BaseURL: srv.URL}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	var buf bytes.Buffer
+
+	err := client.DownloadWorkshopItemContext(ctx, workshopID, &buf)
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestGetWorkshopFileDetailsContextCanceled(t *testing.T) {
+	api := fixture.NewSteamAPI(nil)
+	defer api.Close()
+
+	client := &steamapi.Client{BaseURL: api.URL}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	_, err := client.GetWorkshopFileDetailsContext(ctx, workshopID)
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func detailsServer(t *testing.T, status int, body string) *steamapi.Client {
+	t.Helper()
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		_, _ = w.Write([]byte(body))
+	}))
+	t.Cleanup(srv.Close)
+
+	return &steamapi.Client{BaseURL: srv.URL}
+}
+
+func TestDownloadWorkshopItemErrors(t *testing.T) {
+	api := fixture.NewSteamAPI(nil)
+	defer api.Close()
+
+	err := (&steamapi.Client{BaseURL: api.URL}).DownloadWorkshopItem(workshopID, new(bytes.Buffer))
+	assert.ErrorIs(t, err, steamapi.ErrItemNotFound)
+
+	var resErr *steamapi.ResultError
+	assert.ErrorAs(t, err, &resErr)
+	assert.Equal(t, steamapi.ResultFileNotFound, resErr.Result)
+
+	cases := []struct {
+		name   string
+		status int
+		body   string
+		target error
+	}{
+		{
+			name:   "banned",
+			status: http.StatusOK,
+			body:   `{"response": {"result": 1, "resultcount": 1, "publishedfiledetails": [{"publishedfileid": "1", "result": 1, "file_url": "http://localhost/x", "banned": 1, "ban_reason": "copyright"}]}}`,
+			target: steamapi.ErrItemBanned,
+		},
+		{
+			name:   "access denied",
+			status: http.StatusOK,
+			body:   `{"response": {"result": 1, "resultcount": 1, "publishedfiledetails": [{"publishedfileid": "1", "result": 15}]}}`,
+			target: steamapi.ErrAccessDenied,
+		},
+		{
+			name:   "empty response",
+			status: http.StatusOK,
+			body:   `{"response": {"result": 1, "resultcount": 0}}`,
+			target: steamapi.ErrItemNotFound,
+		},
+	}
+
+	for _, c := range cases {
+		err := detailsServer(t, c.status, c.body).DownloadWorkshopItem(workshopID, new(bytes.Buffer))
+		assert.ErrorIs(t, err, c.target, c.name)
+	}
+
+	err = detailsServer(t, http.StatusOK, `{"response": {"result": 1, "resultcount": 1, "publishedfiledetails": [{"publishedfileid": "1", "result": 1, "banned": 1, "ban_reason": "copyright", "file_url": "x"}]}}`).
+		DownloadWorkshopItem(workshopID, new(bytes.Buffer))
+	assert.EqualError(t, err, "workshop item 1 is banned: copyright")
+}
+
+func TestHTTPStatusError(t *testing.T) {
+	_, err := detailsServer(t, http.StatusServiceUnavailable, "<html>busy</html>").GetWorkshopFileDetails(workshopID)
+
+	var statusErr *steamapi.HTTPStatusError
+	assert.ErrorAs(t, err, &statusErr)
+	assert.Equal(t, http.StatusServiceUnavailable, statusErr.StatusCode)
+
+	_, err = detailsServer(t, http.StatusOK, `{"response": {"result": 2}}`).GetWorkshopFileDetails(workshopID)
+
+	var resErr *steamapi.ResultError
+	assert.ErrorAs(t, err, &resErr)
+	assert.Equal(t, steamapi.ResultFail, resErr.Result)
+}
+
+func TestGetWorkshopFileDetailsBatch(t *testing.T) {
+	items := make(map[int][]byte)
+	for id := 1; id <= 5; id++ {
+		items[id] = []byte("zip")
+	}
+
+	api := fixture.NewSteamAPI(items)
+	defer api.Close()
+
+	client := &steamapi.Client{BaseURL: api.URL, BatchSize: 2}
+
+	details, err := client.GetWorkshopFileDetailsBatch([]int{1, 2, 3, 4, 5, 6, 1})
+	assert.NoError(t, err)
+
+	assert.Len(t, details, 6)
+	assert.Equal(t, 3, api.DetailsRequests(), "6 unique IDs in batches of 2")
+
+	for id := 1; id <= 5; id++ {
+		assert.Equal(t, steamapi.ResultOK, details[id].Result)
+		assert.NoError(t, details[id].Err())
+	}
+
+	assert.Equal(t, steamapi.ResultFileNotFound, details[6].Result)
+	assert.ErrorIs(t, details[6].Err(), steamapi.ErrItemNotFound)
 }
@@ -0,0 +1,346 @@
+// Package vdf implements a parser for Valve's KeyValues text format (also known as VDF - Valve Data Format),
+// as used by e.g. radar overview info files (resource/overviews/<map>.txt).
+package vdf
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"strings"
+	"unicode"
+
+	"github.com/pkg/errors"
+)
+
+// KeyValue is a single node in a KeyValues tree.
+// Leaf nodes have a Value, section nodes have Children.
+type KeyValue struct {
+	Key      string
+	Value    string
+	Children []*KeyValue
+	section  bool
+}
+
+// IsSection returns true if the node is a section (`"key" { ... }`) rather than a `"key" "value"` pair.
+func (kv *KeyValue) IsSection() bool {
+	return kv.section
+}
+
+// Child returns the first direct child with the given key (case-insensitive) or nil if there is none.
+func (kv *KeyValue) Child(key string) *KeyValue {
+	for _, c := range kv.Children {
+		if strings.EqualFold(c.Key, key) {
+			return c
+		}
+	}
+
+	return nil
+}
+
+// String returns the value of the first direct child with the given key (case-insensitive).
+func (kv *KeyValue) String(key string) (string, bool) {
+	c := kv.Child(key)
+	if c == nil || c.section {
+		return "", false
+	}
+
+	return c.Value, true
+}
+
+// Interface converts the node to plain Go values: leaves become strings, sections become map[string]interface{}.
+// If a section contains the same key multiple times, the last value wins.
+func (kv *KeyValue) Interface() interface{} {
+	if !kv.section {
+		return kv.Value
+	}
+
+	m := make(map[string]interface{}, len(kv.Children))
+	for _, c := range kv.Children {
+		m[c.Key] = c.Interface()
+	}
+
+	return m
+}
+
+// MarshalJSON encodes leaves as JSON strings and sections as JSON objects.
+func (kv *KeyValue) MarshalJSON() ([]byte, error) {
+	return json.Marshal(kv.Interface())
+}
+
+// Parse reads a KeyValues document and returns a section node containing all top-level nodes as children.
+func Parse(r io.Reader) (*KeyValue, error) {
+	p := &parser{r: bufio.NewReader(r), line: 1}
+
+	root := &KeyValue{section: true}
+
+	err := p.parseSection(root, false)
+	if err != nil {
+		return nil, err
+	}
+
+	return root, nil
+}
+
+// ParseBytes is a convenience wrapper around Parse.
+func ParseBytes(b []byte) (*KeyValue, error) {
+	return Parse(bytes.NewReader(b))
+}
+
+type tokenType int
+
+const (
+	tokenEOF tokenType = iota
+	tokenString
+	tokenOpen
+	tokenClose
+	tokenConditional
+)
+
+type token struct {
+	typ tokenType
+	val string
+}
+
+type parser struct {
+	r    *bufio.Reader
+	line int
+}
+
+func (p *parser) errorf(format string, args ...interface{}) error {
+	return errors.Errorf("vdf: line %d: "+format, append([]interface{}{p.line}, args...)...)
+}
+
+func (p *parser) parseSection(parent *KeyValue, nested bool) error {
+	for {
+		tok, err := p.next()
+		if err != nil {
+			return err
+		}
+
+		switch tok.typ {
+		case tokenEOF:
+			if nested {
+				return p.errorf("unexpected end of input, missing '}' for section %q", parent.Key)
+			}
+
+			return nil
+
+		case tokenClose:
+			if !nested {
+				return p.errorf("unexpected '}'")
+			}
+
+			return nil
+
+		case tokenOpen, tokenConditional:
+			return p.errorf("unexpected token %q, expected key", tok.val)
+		}
+
+		// #include / #base directives reference other files which we can't resolve
+		if strings.HasPrefix(tok.val, "#") {
+			_, err = p.next()
+			if err != nil {
+				return err
+			}
+
+			continue
+		}
+
+		kv := &KeyValue{Key: tok.val}
+
+		tok, err = p.next()
+		if err != nil {
+			return err
+		}
+
+		switch tok.typ {
+		case tokenString:
+			kv.Value = tok.val
+
+		case tokenOpen:
+			kv.section = true
+
+			err = p.parseSection(kv, true)
+			if err != nil {
+				return err
+			}
+
+		default:
+			return p.errorf("unexpected token %q after key %q", tok.val, kv.Key)
+		}
+
+		parent.Children = append(parent.Children, kv)
+
+		err = p.skipConditional()
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// skipConditional consumes an optional platform conditional (e.g. [$WIN32]) following a key-value pair.
+func (p *parser) skipConditional() error {
+	err := p.skipSpaceAndComments()
+	if err != nil {
+		return err
+	}
+
+	b, err := p.r.Peek(1)
+	if err == io.EOF {
+		return nil
+	} else if err != nil {
+		return errors.WithStack(err)
+	}
+
+	if b[0] != '[' {
+		return nil
+	}
+
+	_, err = p.next()
+
+	return err
+}
+
+func (p *parser) skipSpaceAndComments() error {
+	for {
+		b, err := p.r.Peek(2)
+		if len(b) == 0 && err == io.EOF {
+			return nil
+		} else if len(b) == 0 && err != nil {
+			return errors.WithStack(err)
+		}
+
+		if len(b) == 2 && b[0] == '/' && b[1] == '/' {
+			_, err = p.r.ReadString('\n')
+			if err != nil && err != io.EOF {
+				return errors.WithStack(err)
+			}
+
+			p.line++
+
+			continue
+		}
+
+		c, _, err := p.r.ReadRune()
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if !unicode.IsSpace(c) && c != '\ufeff' {
+			return errors.WithStack(p.r.UnreadRune())
+		}
+
+		if c == '\n' {
+			p.line++
+		}
+	}
+}
+
+func (p *parser) next() (token, error) {
+	err := p.skipSpaceAndComments()
+	if err != nil {
+		return token{}, err
+	}
+
+	c, _, err := p.r.ReadRune()
+	if err == io.EOF {
+		return token{typ: tokenEOF}, nil
+	} else if err != nil {
+		return token{}, errors.WithStack(err)
+	}
+
+	switch c {
+	case '{':
+		return token{typ: tokenOpen, val: "{"}, nil
+
+	case '}':
+		return token{typ: tokenClose, val: "}"}, nil
+
+	case '[':
+		s, err := p.r.ReadString(']')
+		if err != nil {
+			return token{}, p.errorf("unterminated conditional")
+		}
+
+		return token{typ: tokenConditional, val: "[" + s}, nil
+
+	case '"':
+		return p.quoted()
+	}
+
+	err = p.r.UnreadRune()
+	if err != nil {
+		return token{}, errors.WithStack(err)
+	}
+
+	return p.unquoted()
+}
+
+func (p *parser) quoted() (token, error) {
+	var sb strings.Builder
+
+	for {
+		c, _, err := p.r.ReadRune()
+		if err == io.EOF {
+			return token{}, p.errorf("unterminated string")
+		} else if err != nil {
+			return token{}, errors.WithStack(err)
+		}
+
+		switch c {
+		case '"':
+			return token{typ: tokenString, val: sb.String()}, nil
+
+		case '\n':
+			p.line++
+
+		case '\\':
+			esc, _, err := p.r.ReadRune()
+			if err != nil {
+				return token{}, p.errorf("unterminated string")
+			}
+
+			switch esc {
+			case 'n':
+				c = '\n'
+			case 't':
+				c = '\t'
+			case '\\', '"':
+				c = esc
+			default:
+				// not an escape sequence we know (e.g. a windows path) - keep it verbatim
+				sb.WriteRune('\\')
+				c = esc
+			}
+		}
+
+		sb.WriteRune(c)
+	}
+}
+
+func (p *parser) unquoted() (token, error) {
+	var sb strings.Builder
+
+	for {
+		c, _, err := p.r.ReadRune()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return token{}, errors.WithStack(err)
+		}
+
+		if unicode.IsSpace(c) || c == '"' || c == '{' || c == '}' || c == '[' {
+			err = p.r.UnreadRune()
+			if err != nil {
+				return token{}, errors.WithStack(err)
+			}
+
+			break
+		}
+
+		sb.WriteRune(c)
+	}
+
+	return token{typ: tokenString, val: sb.String()}, nil
+}
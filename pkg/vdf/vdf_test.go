@@ -0,0 +1,79 @@
+package vdf_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/saiko-tech/csgo-centrifuge/pkg/vdf"
+)
+
+const sample = `// comment before the root
+"de_nuke"
+{
+	"material"	"overviews/de_nuke" // trailing comment
+	pos_x		-3453
+	"escaped"	"say \"hi\"\tpath\maps"
+	"verticalsections"
+	{
+		"default"
+		{
+			"AltitudeMax" "10000"
+			"AltitudeMin" "-495"
+		}
+	}
+	"platform"	"pc"	[$WIN32]
+}
+`
+
+func TestParse(t *testing.T) {
+	root, err := vdf.ParseBytes([]byte(sample))
+	assert.NoError(t, err)
+
+	assert.Len(t, root.Children, 1)
+
+	nuke := root.Child("DE_NUKE")
+	assert.NotNil(t, nuke)
+	assert.True(t, nuke.IsSection())
+
+	material, ok := nuke.String("material")
+	assert.True(t, ok)
+	assert.Equal(t, "overviews/de_nuke", material)
+
+	posX, ok := nuke.String("pos_x")
+	assert.True(t, ok)
+	assert.Equal(t, "-3453", posX)
+
+	escaped, _ := nuke.String("escaped")
+	assert.Equal(t, "say \"hi\"\tpath\\maps", escaped)
+
+	def := nuke.Child("verticalsections").Child("default")
+	assert.NotNil(t, def)
+
+	altMin, _ := def.String("altitudemin")
+	assert.Equal(t, "-495", altMin)
+
+	platform, _ := nuke.String("platform")
+	assert.Equal(t, "pc", platform)
+}
+
+func TestParseErrors(t *testing.T) {
+	_, err := vdf.ParseBytes([]byte(`"a" { "b" "c"`))
+	assert.Error(t, err)
+
+	_, err = vdf.ParseBytes([]byte(`"a" "b" }`))
+	assert.Error(t, err)
+
+	_, err = vdf.ParseBytes([]byte(`"a" "unterminated`))
+	assert.Error(t, err)
+}
+
+func TestMarshalJSON(t *testing.T) {
+	root, err := vdf.ParseBytes([]byte(`"a" { "b" "1" "c" { "d" "2" } }`))
+	assert.NoError(t, err)
+
+	b, err := json.Marshal(root.Child("a"))
+	assert.NoError(t, err)
+	assert.JSONEq(t, `{"b": "1", "c": {"d": "2"}}`, string(b))
+}